@@ -3,8 +3,62 @@
 // SPDX-License-Identifier: MIT
 // Filename: cmd/c8asm/main.go
 
-package c8asm
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexflint/go-arg"
+	"github.com/codemicro/chip8/internal/assembler"
+	"github.com/codemicro/chip8/internal/assembler/lex"
+	"github.com/codemicro/chip8/internal/assembler/parse"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var args struct {
+	InputFile    string   `arg:"positional,required"`
+	OutputFile   string   `arg:"-o,--output" help:"output ROM filename (defaults to the input filename with a .ch8 extension)"`
+	StripUnused  bool     `arg:"--strip-unused" help:"remove subroutines that are never referenced from the output"`
+	IncludePaths []string `arg:"-I,--include,separate" help:"additional directory to search for included files (may be repeated)"`
+}
+
+func e(err error) {
+	var aerr *assembler.Error
+	if errors.As(err, &aerr) && aerr.SourceLine == "" && aerr.File != "" {
+		// errors from the parser don't include the offending source line
+		if src, rerr := ioutil.ReadFile(aerr.File); rerr == nil {
+			aerr.SourceLine = assembler.SourceLine(src, aerr.Line)
+		}
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
 
 func main() {
 
+	arg.MustParse(&args)
+
+	tokens, err := lex.LexFile(args.InputFile, args.IncludePaths)
+	if err != nil {
+		e(err)
+	}
+
+	rom, err := parse.Parse(tokens, &parse.Options{
+		StripUnusedSubroutines: args.StripUnused,
+	})
+	if err != nil {
+		e(err)
+	}
+
+	outputFile := args.OutputFile
+	if outputFile == "" {
+		outputFile = strings.TrimSuffix(args.InputFile, filepath.Ext(args.InputFile)) + ".ch8"
+	}
+
+	if err = ioutil.WriteFile(outputFile, rom, 0644); err != nil {
+		e(err)
+	}
 }
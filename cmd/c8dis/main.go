@@ -0,0 +1,45 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: cmd/c8dis/main.go
+
+package main
+
+import (
+	"fmt"
+	"github.com/alexflint/go-arg"
+	"github.com/codemicro/chip8/internal/disassembler"
+	"io/ioutil"
+	"os"
+)
+
+var args struct {
+	InputFile  string `arg:"positional,required"`
+	OutputFile string `arg:"-o,--output" help:"output source filename (defaults to stdout)"`
+}
+
+func e(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func main() {
+
+	arg.MustParse(&args)
+
+	rom, err := ioutil.ReadFile(args.InputFile)
+	if err != nil {
+		e(err)
+	}
+
+	src := disassembler.Disassemble(rom)
+
+	if args.OutputFile == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(args.OutputFile, src, 0644)
+	}
+	if err != nil {
+		e(err)
+	}
+}
@@ -6,13 +6,24 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"errors"
 	"fmt"
 	"github.com/alexflint/go-arg"
+	"github.com/codemicro/chip8/internal/emulator/runner"
 	"github.com/codemicro/chip8/internal/emulator/ui"
 	vm2 "github.com/codemicro/chip8/internal/emulator/vm"
+	"github.com/codemicro/chip8/internal/movie"
+	"github.com/codemicro/chip8/internal/romdb"
+	"github.com/hajimehoshi/ebiten/v2"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
 var args struct {
@@ -20,9 +31,46 @@ var args struct {
 	DebugMode bool   `arg:"-d,-v,--verbose" help:"enable verbose/debug mode"`
 	UIScale   int    `arg:"-s,--scale" help:"UI scale factor" default:"5"`
 	ToneFrequency int `arg:"--frequency" help:"sound timer tone frequency" default:"350"`
-	ClockSpeed int `arg:"-c,--clock" help:"approximate clock speed in hertz" default:"500"`
-	FgColour string `arg:"-f,--foreground" help:"foreground hex colour" default:"3D8026"`
-	BgColour string `arg:"-b,--background" help:"background hex colour" default:"F9FFB3"`
+	ClockSpeed int `arg:"-c,--clock" help:"approximate clock speed in hertz [default: 500, or as set in the ROM database]"`
+	FgColour string `arg:"-f,--foreground" help:"foreground hex colour [default: 3D8026, or as set in the ROM database]"`
+	BgColour string `arg:"-b,--background" help:"background hex colour [default: F9FFB3, or as set in the ROM database]"`
+	Fg2Colour string `arg:"--foreground2" help:"hex colour of pixels set only in the second XO-CHIP drawing plane [default: C2571A, or as set in the ROM database]"`
+	BlendColour string `arg:"--blend" help:"hex colour of pixels set in both XO-CHIP drawing planes [default: 1B3A4B, or as set in the ROM database]"`
+	NoROMDatabase bool `arg:"--no-romdb" help:"don't look up the ROM in the built-in ROM database"`
+	MemoryPolicy string `arg:"--memory-policy" help:"what to do when the program accesses memory out of bounds (wrap, fault or ignore)" default:"wrap"`
+	MemorySize int `arg:"--memory-size" help:"size of the address space in bytes [default: 65536 with the xo-chip quirks preset, otherwise 4096]"`
+	StackDepth int `arg:"--stack-depth" help:"maximum number of nested subroutine calls, or 0 for unlimited" default:"16"`
+	RewindSeconds int `arg:"--rewind" help:"seconds of history to keep for rewinding, or 0 to disable rewinding" default:"10"`
+	Seed *int64 `arg:"--seed" help:"seed for the random number generator, to make runs reproducible [default: based on the current time]"`
+	Record string `arg:"--record" help:"record the keys pressed in every frame to a movie file"`
+	Play string `arg:"--play" help:"play back a movie file made with --record, using the settings it was recorded with"`
+
+	QuirksPreset                     string `arg:"-q,--quirks" help:"quirks preset (vip, schip-legacy, schip-modern, xo-chip or custom) [default: custom, or as set in the ROM database]"`
+	CopyRegistersOnShift             *bool  `arg:"--copy-registers-on-shift" help:"override quirk: 8XY6/8XYE copy VY into VX before shifting"`
+	VariableOffsetRegister           *bool  `arg:"--variable-offset-register" help:"override quirk: BNNN jumps to NNN + VX instead of NNN + V0"`
+	DisableSetFlagOnIrOverflow       *bool  `arg:"--disable-set-flag-on-ir-overflow" help:"override quirk: FX1E does not set VF on overflow"`
+	IncrementIndexRegisterOnLoadSave *bool  `arg:"--increment-index-on-load-save" help:"override quirk: FX55/FX65 increment the index register"`
+	ResetFlagOnLogic                 *bool  `arg:"--reset-flag-on-logic" help:"override quirk: 8XY1/8XY2/8XY3 reset VF"`
+	ClipSprites                      *bool  `arg:"--clip-sprites" help:"override quirk: DXYN clips sprites at the edge of the display instead of wrapping"`
+	WaitForVBlank                    *bool  `arg:"--wait-for-vblank" help:"override quirk: DXYN waits for the next 60Hz tick"`
+}
+
+const (
+	defaultClockSpeed   = 500
+	defaultFgColour     = "3D8026"
+	defaultBgColour     = "F9FFB3"
+	defaultFg2Colour    = "C2571A"
+	defaultBlendColour  = "1B3A4B"
+	defaultQuirksPreset = vm2.DefaultQuirksPreset
+
+	// clockSpeedStep is the amount the clock speed changes by when using the speed hotkeys
+	clockSpeedStep = 100
+)
+
+// saveSlotKeys are the hotkeys for each save state slot, in slot order
+var saveSlotKeys = []ebiten.Key{
+	ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4, ebiten.KeyF5, ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8,
+	ebiten.KeyF9,
 }
 
 func e(err error) {
@@ -30,6 +78,98 @@ func e(err error) {
 	os.Exit(1)
 }
 
+// quirks returns the preset selected with --quirks, modified by any individual quirk flags
+func quirks() (vm2.Quirks, error) {
+	q, err := vm2.QuirksPreset(args.QuirksPreset)
+	if err != nil {
+		return q, err
+	}
+
+	for _, override := range []struct {
+		flag  *bool
+		quirk *bool
+	}{
+		{args.CopyRegistersOnShift, &q.CopyRegistersOnShift},
+		{args.VariableOffsetRegister, &q.VariableOffsetRegister},
+		{args.DisableSetFlagOnIrOverflow, &q.DisableSetFlagOnIrOverflow},
+		{args.IncrementIndexRegisterOnLoadSave, &q.IncrementIndexRegisterOnLoadSave},
+		{args.ResetFlagOnLogic, &q.ResetFlagOnLogic},
+		{args.ClipSprites, &q.ClipSprites},
+		{args.WaitForVBlank, &q.WaitForVBlank},
+	} {
+		if override.flag != nil {
+			*override.quirk = *override.flag
+		}
+	}
+
+	return q, nil
+}
+
+// applyROMDatabase fills in any settings that weren't given on the command line using the ROM database entry for rom,
+// if there is one, and then with the defaults. It returns the title to use for the window.
+func applyROMDatabase(rom []byte) (string, error) {
+	title := filepath.Base(args.InputFile)
+
+	entry := &romdb.Entry{}
+	if !args.NoROMDatabase {
+		db, err := romdb.Embedded()
+		if err != nil {
+			return "", err
+		}
+		if x, found := db.Lookup(rom); found {
+			entry = x
+			printROMInfo(entry)
+			if entry.Title != "" {
+				title = entry.Title
+			}
+		}
+	}
+
+	fallback := func(arg *string, values ...string) {
+		for _, v := range values {
+			if *arg == "" {
+				*arg = v
+			}
+		}
+	}
+
+	fallback(&args.FgColour, entry.Foreground, defaultFgColour)
+	fallback(&args.BgColour, entry.Background, defaultBgColour)
+	fallback(&args.Fg2Colour, entry.Foreground2, defaultFg2Colour)
+	fallback(&args.BlendColour, entry.Blend, defaultBlendColour)
+	fallback(&args.QuirksPreset, entry.Quirks, defaultQuirksPreset)
+
+	if args.ClockSpeed == 0 {
+		args.ClockSpeed = entry.ClockSpeed
+	}
+	if args.ClockSpeed == 0 {
+		args.ClockSpeed = defaultClockSpeed
+	}
+
+	return title, nil
+}
+
+// printROMInfo prints the details of a ROM found in the ROM database
+func printROMInfo(entry *romdb.Entry) {
+	info := entry.Title
+	if entry.Author != "" {
+		info += " by " + entry.Author
+	}
+	if entry.Platform != "" {
+		info += " (" + entry.Platform + ")"
+	}
+	fmt.Println(info)
+
+	var keys []string
+	for k := range entry.Keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %s: %s\n", strings.ToUpper(k), entry.Keys[k])
+	}
+}
+
 func main() {
 
 	arg.MustParse(&args)
@@ -39,16 +179,279 @@ func main() {
 		e(err)
 	}
 
-	disp, err := ui.NewUI(64, 32, args.UIScale, filepath.Base(args.InputFile), args.ToneFrequency, args.FgColour, args.BgColour)
+	title, err := applyROMDatabase(fcont)
+	if err != nil {
+		e(err)
+	}
+
+	q, err := quirks()
+	if err != nil {
+		e(err)
+	}
+
+	memoryPolicy, err := vm2.ParseMemoryPolicy(args.MemoryPolicy)
+	if err != nil {
+		e(err)
+	}
+	if args.MemorySize == 0 {
+		args.MemorySize = vm2.DefaultMemorySize
+		if strings.EqualFold(args.QuirksPreset, "xo-chip") {
+			args.MemorySize = vm2.LargeMemorySize
+		}
+	}
+	if args.MemorySize < 0 || args.MemorySize > vm2.LargeMemorySize {
+		e(fmt.Errorf("memory size must be between 1 and %d bytes", vm2.LargeMemorySize))
+	}
+
+	seed := time.Now().UnixNano()
+	if args.Seed != nil {
+		seed = *args.Seed
+	}
+
+	if args.Record != "" && args.Play != "" {
+		e(errors.New("--record and --play can't be used together"))
+	}
+
+	header := movie.Header{
+		ROMHash:       sha1.Sum(fcont),
+		Seed:          seed,
+		ClockSpeed:    uint32(args.ClockSpeed),
+		Quirks:        q,
+		MemoryPolicy:  memoryPolicy,
+		MemorySize:    uint32(args.MemorySize),
+		MaxStackDepth: uint32(args.StackDepth),
+	}
+
+	var player *movie.Player
+	if args.Play != "" {
+		player, err = loadMovie(args.Play, fcont)
+		if err != nil {
+			e(err)
+		}
+		header = player.Header()
+	}
+
+	disp, err := ui.NewUI(vm2.LowResWidth, vm2.LowResHeight, args.UIScale, title, args.ToneFrequency, [4]string{
+		args.BgColour,
+		args.FgColour,
+		args.Fg2Colour,
+		args.BlendColour,
+	})
 	if err != nil {
 		e(err)
 	}
 
-	vm := vm2.NewChip8(fcont, disp, args.ClockSpeed)
+	vm := vm2.NewChip8(fcont, disp, int(header.ClockSpeed))
 	vm.Debug = args.DebugMode
-	go vm.Run()
+	vm.Quirks = header.Quirks
+	vm.MemoryPolicy = header.MemoryPolicy
+	vm.MemorySize = int(header.MemorySize)
+	vm.MaxStackDepth = int(header.MaxStackDepth)
+
+	vm.SetRandomSource(vm2.NewRandomSource(header.Seed))
+	if args.DebugMode {
+		fmt.Println("random seed:", header.Seed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var recorder *movie.Recorder
+	var movieFile *os.File
+	switch {
+	case args.Record != "":
+		movieFile, err = os.Create(args.Record)
+		if err != nil {
+			e(err)
+		}
+		recorder, err = movie.NewRecorder(movieFile, header, disp)
+		if err != nil {
+			e(err)
+		}
+		vm.SetInputSource(recorder)
+	case player != nil:
+		vm.SetInputSource(player)
+		go func() {
+			select {
+			case <-player.Done():
+				disp.SetStatus("playback finished")
+			case <-ctx.Done():
+			}
+		}()
+	default:
+		vm.EnableRewind(args.RewindSeconds)
+	}
+
+	run := runner.New(vm, func(err error) {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			disp.SetError(err)
+		} else {
+			disp.SetStatus("exited")
+		}
+	})
+	addControls(disp, vm, run, recorder != nil || player != nil)
+
+	vmErr := make(chan error, 1)
+	go func() {
+		vmErr <- run.Run(ctx)
+	}()
 
 	if err = disp.Start(); err != nil {
 		e(err)
 	}
+
+	// the window was closed - stop the VM before finishing the recording, then exit with an error if the VM stopped
+	// because of one
+	cancel()
+	err = <-vmErr
+
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			e(err)
+		}
+		if err := movieFile.Close(); err != nil {
+			e(err)
+		}
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// loadMovie reads the movie at path and checks that it was recorded with rom
+func loadMovie(path string, rom []byte) (*movie.Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	player, err := movie.Load(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	if err := player.Header().CheckROM(rom); err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+// addControls adds hotkeys to disp to pause, reset and change the speed of vm. run is restarted when vm is reset, if
+// the program had stopped.
+//
+// If pauseOnly is true, only the pause hotkey is added, as every other control would change the course of the program
+// in a way that can't be recorded in a movie.
+func addControls(disp *ui.UI, vm *vm2.Chip8, run *runner.Runner, pauseOnly bool) {
+	disp.AddHotkey(ebiten.KeyP, func() {
+		if vm.Paused() {
+			vm.Resume()
+			disp.SetStatus("")
+		} else {
+			vm.Pause()
+			disp.SetStatus("paused")
+		}
+	})
+
+	if pauseOnly {
+		return
+	}
+
+	disp.AddHotkey(ebiten.KeyHome, func() {
+		vm.Reset()
+		if vm.Paused() {
+			disp.SetStatus("paused")
+		} else {
+			disp.SetStatus("")
+		}
+		run.Restart()
+	})
+
+	changeSpeed := func(delta int) func() {
+		return func() {
+			hz := vm.ClockSpeed() + delta
+			if hz < clockSpeedStep {
+				hz = clockSpeedStep
+			}
+			vm.SetClockSpeed(hz)
+			disp.SetStatus(fmt.Sprintf("%dHz", hz))
+		}
+	}
+	disp.AddHotkey(ebiten.KeyPageUp, changeSpeed(clockSpeedStep))
+	disp.AddHotkey(ebiten.KeyPageDown, changeSpeed(-clockSpeedStep))
+
+	// rewinding pauses the VM, so remember whether it was already paused to know whether to resume it afterwards, and
+	// whether the program had stopped to know whether it needs restarting
+	var rewinding, pausedBeforeRewind, stoppedBeforeRewind bool
+	disp.AddHeldHotkey(ebiten.KeyBackspace, func() {
+		if !rewinding {
+			rewinding = true
+			pausedBeforeRewind = vm.Paused()
+			stoppedBeforeRewind = run.Stopped()
+			vm.Pause()
+		}
+		if vm.RewindFrame() {
+			disp.SetStatus("rewinding")
+		} else {
+			disp.SetStatus("no more history")
+		}
+	}, func() {
+		rewinding = false
+		if pausedBeforeRewind {
+			disp.SetStatus("paused")
+		} else {
+			vm.Resume()
+			disp.SetStatus("")
+		}
+		if stoppedBeforeRewind {
+			run.Restart()
+		}
+	})
+
+	for i, key := range saveSlotKeys {
+		slot := i + 1
+		disp.AddHotkey(key, func() {
+			if ebiten.IsKeyPressed(ebiten.KeyShift) {
+				if err := saveState(vm, slot); err != nil {
+					disp.SetStatus(fmt.Sprintf("failed to save slot %d: %v", slot, err))
+					return
+				}
+				disp.SetStatus(fmt.Sprintf("saved slot %d", slot))
+				return
+			}
+
+			if err := loadState(vm, slot); err != nil {
+				disp.SetStatus(fmt.Sprintf("failed to load slot %d: %v", slot, err))
+				return
+			}
+			disp.SetStatus(fmt.Sprintf("loaded slot %d", slot))
+			run.Restart()
+		})
+	}
+}
+
+// statePath returns the path of the file for a save state slot, which is next to the ROM
+func statePath(slot int) string {
+	return fmt.Sprintf("%s.state%d", strings.TrimSuffix(args.InputFile, filepath.Ext(args.InputFile)), slot)
+}
+
+func saveState(vm *vm2.Chip8, slot int) error {
+	f, err := os.Create(statePath(slot))
+	if err != nil {
+		return err
+	}
+	if err := vm.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func loadState(vm *vm2.Chip8, slot int) error {
+	f, err := os.Open(statePath(slot))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return vm.LoadState(bufio.NewReader(f))
 }
@@ -0,0 +1,165 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: cmd/c8test/main.go
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"github.com/alexflint/go-arg"
+	"github.com/codemicro/chip8/internal/emulator/headless"
+	vm2 "github.com/codemicro/chip8/internal/emulator/vm"
+	"github.com/codemicro/chip8/internal/movie"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var args struct {
+	InputFile    string `arg:"positional,required"`
+	Frames       int    `arg:"-n,--frames" help:"number of 60Hz frames to run the ROM for" default:"60"`
+	Output       string `arg:"-o,--output" help:"write the final display to this file (.png, .pbm or .txt) [default: ASCII art on stdout, unless --golden is given]"`
+	Golden       string `arg:"-g,--golden" help:"compare the final display to this file (.png, .pbm or .txt) and exit with an error if they differ"`
+	ClockSpeed   int    `arg:"-c,--clock" help:"approximate clock speed in hertz" default:"500"`
+	QuirksPreset string `arg:"-q,--quirks" help:"quirks preset (vip, schip-legacy, schip-modern, xo-chip or custom)" default:"custom"`
+	Seed         int64  `arg:"--seed" help:"seed for the random number generator" default:"0"`
+	Play         string `arg:"--play" help:"play back a movie file made with c8run --record, using the settings it was recorded with"`
+}
+
+func e(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func main() {
+
+	arg.MustParse(&args)
+
+	rom, err := ioutil.ReadFile(args.InputFile)
+	if err != nil {
+		e(err)
+	}
+
+	q, err := vm2.QuirksPreset(args.QuirksPreset)
+	if err != nil {
+		e(err)
+	}
+
+	memorySize := vm2.DefaultMemorySize
+	if strings.EqualFold(args.QuirksPreset, "xo-chip") {
+		memorySize = vm2.LargeMemorySize
+	}
+
+	header := movie.Header{
+		ROMHash:       sha1.Sum(rom),
+		Seed:          args.Seed,
+		ClockSpeed:    uint32(args.ClockSpeed),
+		Quirks:        q,
+		MemoryPolicy:  vm2.MemoryWrap,
+		MemorySize:    uint32(memorySize),
+		MaxStackDepth: vm2.DefaultStackDepth,
+	}
+
+	var player *movie.Player
+	if args.Play != "" {
+		player, err = loadMovie(args.Play, rom)
+		if err != nil {
+			e(err)
+		}
+		header = player.Header()
+	}
+
+	ui := &headless.UI{}
+	vm := vm2.NewChip8(rom, ui, int(header.ClockSpeed))
+	vm.Quirks = header.Quirks
+	vm.MemoryPolicy = header.MemoryPolicy
+	vm.MemorySize = int(header.MemorySize)
+	vm.MaxStackDepth = int(header.MaxStackDepth)
+	vm.SetRandomSource(vm2.NewRandomSource(header.Seed))
+	if player != nil {
+		vm.SetInputSource(player)
+	}
+
+	for i := 0; i < args.Frames && !vm.Exited(); i += 1 {
+		if err := vm.RunFrame(); err != nil {
+			e(err)
+		}
+	}
+
+	if args.Output != "" {
+		if err := writeDisplay(args.Output, &ui.Display); err != nil {
+			e(err)
+		}
+	} else if args.Golden == "" {
+		if err := headless.Encode(os.Stdout, &ui.Display, headless.FormatASCII); err != nil {
+			e(err)
+		}
+	}
+
+	if args.Golden != "" {
+		match, err := compareDisplay(args.Golden, &ui.Display)
+		if err != nil {
+			e(err)
+		}
+		if !match {
+			fmt.Fprintf(os.Stderr, "final display does not match %s, got:\n", args.Golden)
+			_ = headless.Encode(os.Stderr, &ui.Display, headless.FormatASCII)
+			os.Exit(1)
+		}
+	}
+}
+
+// loadMovie reads the movie at path and checks that it was recorded with rom
+func loadMovie(path string, rom []byte) (*movie.Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	player, err := movie.Load(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	if err := player.Header().CheckROM(rom); err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+// writeDisplay writes d to path, in the format given by its extension
+func writeDisplay(path string, d *vm2.Display) error {
+	format, err := headless.FormatFromFilename(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := headless.Encode(f, d, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// compareDisplay reports whether d matches the image at path, in the format given by its extension
+func compareDisplay(path string, d *vm2.Display) (bool, error) {
+	format, err := headless.FormatFromFilename(path)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return headless.Compare(d, bufio.NewReader(f), format)
+}
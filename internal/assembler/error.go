@@ -0,0 +1,83 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/error.go
+
+package assembler
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"strings"
+)
+
+// Error is an error that occurred at a specific position in an assembler source file
+type Error struct {
+	token.Position
+	Message string
+	// SourceLine is the line of source that the error occurred on, and may be empty if it is not known
+	SourceLine string
+}
+
+func NewError(pos token.Position, message string) *Error {
+	return &Error{
+		Position: pos,
+		Message:  message,
+	}
+}
+
+func Errorf(pos token.Position, format string, a ...interface{}) *Error {
+	return NewError(pos, fmt.Sprintf(format, a...))
+}
+
+// Error returns the error message prefixed with its position in the form `file:line:column`. If SourceLine is set,
+// the line is included below the message with a caret underneath the column the error occurred at.
+func (e *Error) Error() string {
+	var sb strings.Builder
+
+	var location []string
+	if e.File != "" {
+		location = append(location, e.File)
+	}
+	if e.Line != 0 {
+		location = append(location, fmt.Sprint(e.Line))
+		if e.Column != 0 {
+			location = append(location, fmt.Sprint(e.Column))
+		}
+	}
+	if len(location) != 0 {
+		sb.WriteString(strings.Join(location, ":"))
+		sb.WriteString(": ")
+	}
+
+	sb.WriteString(e.Message)
+
+	if e.SourceLine != "" && e.Column != 0 {
+		sb.WriteString("\n    ")
+		sb.WriteString(e.SourceLine)
+		sb.WriteString("\n    ")
+		for i, r := range e.SourceLine {
+			if i >= e.Column-1 {
+				break
+			}
+			if r == '\t' {
+				sb.WriteRune('\t')
+			} else {
+				sb.WriteRune(' ')
+			}
+		}
+		sb.WriteRune('^')
+	}
+
+	return sb.String()
+}
+
+// SourceLine returns the contents of the given line of src, without a trailing newline. Lines are numbered from 1.
+func SourceLine(src []byte, line int) string {
+	lines := bytes.Split(src, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(string(lines[line-1]), "\r")
+}
@@ -0,0 +1,28 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/error_test.go
+
+package assembler
+
+import (
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"testing"
+)
+
+func Test_Error(t *testing.T) {
+	src := []byte("main:\n\tset $0 0x1FF\n")
+
+	e := Errorf(token.Position{File: "main.asm", Line: 2, Column: 9}, "value %d out of range", 0x1FF)
+	e.SourceLine = SourceLine(src, e.Line)
+
+	want := "main.asm:2:9: value 511 out of range\n    \tset $0 0x1FF\n    \t       ^"
+	if got := e.Error(); got != want {
+		t.Fatalf("incorrect error message\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	e = NewError(token.Position{Line: 4}, "no file or column")
+	if got, want := e.Error(), "4: no file or column"; got != want {
+		t.Fatalf("incorrect error message (got %q, want %q)", got, want)
+	}
+}
@@ -19,31 +19,64 @@ const (
 	keywordSubroutine = "subroutine"
 )
 
-func lexAtDeclaration(peek func(offset int) rune, consume func() rune) (token.Token, error) {
+func lexAtDeclaration(peek func(offset int) rune, consume func() rune, pos func() token.Position) (token.Token, error) {
 
 	if peek(0) != '@' {
 		return nil, errors.New("expecting @")
 	}
+	start := pos()
 	consume()
 
 	if strings.EqualFold(peekMultiple(peek, 0, len(keywordDefine)), keywordDefine) {
 		consumeMultiple(consume, len(keywordDefine))
-		return lexDefine(peek, consume)
+		d, err := lexDefine(peek, consume, pos)
+		if err != nil {
+			return nil, err
+		}
+		d.Position = start
+		return d, nil
 	} else if strings.EqualFold(peekMultiple(peek, 0, len(keywordInclude)), keywordInclude) {
 		consumeMultiple(consume, len(keywordInclude))
-		return lexInclude(peek, consume)
+		i, err := lexInclude(peek, consume)
+		if err != nil {
+			return nil, err
+		}
+		i.Position = start
+		return i, nil
 	} else if strings.EqualFold(peekMultiple(peek, 0, len(keywordMacro)), keywordMacro) {
 		consumeMultiple(consume, len(keywordMacro))
-		return lexMacro(peek, consume)
+		m, err := lexMacro(peek, consume, pos)
+		if err != nil {
+			return nil, err
+		}
+		m.Position = start
+		return m, nil
 	} else if strings.EqualFold(peekMultiple(peek, 0, len(keywordSubroutine)), keywordSubroutine) {
 		consumeMultiple(consume, len(keywordSubroutine))
-		return lexSubroutine(peek, consume)
+		s, err := lexSubroutine(peek, consume, pos)
+		if err != nil {
+			return nil, err
+		}
+		s.Position = start
+		return s, nil
+	}
+
+	for _, directive := range dataDirectives {
+		if strings.EqualFold(peekMultiple(peek, 0, len(directive)), directive) {
+			consumeMultiple(consume, len(directive))
+			d, err := lexData(peek, consume, pos, directive)
+			if err != nil {
+				return nil, err
+			}
+			d.Position = start
+			return d, nil
+		}
 	}
 
 	return nil, errors.New("unknown @ declaration")
 }
 
-func lexDefine(peek func(offset int) rune, consume func() rune) (*token.Define, error) {
+func lexDefine(peek func(offset int) rune, consume func() rune, pos func() token.Position) (*token.Define, error) {
 
 	if peek(0) != ' ' {
 		return nil, errors.New("expecting value in @define")
@@ -62,15 +95,18 @@ func lexDefine(peek func(offset int) rune, consume func() rune) (*token.Define,
 	consume()
 
 	// value
-	val, err := lexValue(peek, consume)
+	val, err := lexValue(peek, consume, pos)
 	if err != nil {
 		return nil, err
 	}
-	if val.Type() != token.TypeValue {
+	if val.Type() == token.TypeRegister {
 		return nil, errors.New("define must define a constant value")
 	}
 
-	if x := peek(0); !(x == '\n' || x == 0) {
+	for isWhitespace(peek(0)) {
+		consume()
+	}
+	if x := peek(0); !(x == '\n' || x == 0 || x == ';') {
 		return nil, errors.New("expected end of line")
 	}
 
@@ -96,12 +132,17 @@ func lexInclude(peek func(offset int) rune, consume func() rune) (*token.Include
 		o = append(o, consume())
 	}
 
+	filename := strings.Trim(strings.TrimSpace(string(o)), "\"")
+	if filename == "" {
+		return nil, errors.New("expecting filename in @include")
+	}
+
 	return &token.Include{
-		Filename: string(o),
+		Filename: filename,
 	}, nil
 }
 
-func lexMacro(peek func(offset int) rune, consume func() rune) (*token.Macro, error) {
+func lexMacro(peek func(offset int) rune, consume func() rune, pos func() token.Position) (*token.Macro, error) {
 
 	const keywordEndMacro = "@endmacro"
 
@@ -181,7 +222,13 @@ func lexMacro(peek func(offset int) rune, consume func() rune) (*token.Macro, er
 	var instructions []*token.Instruction
 
 	for {
-		if peekMultiple(peek, 0, len(keywordEndMacro)) == keywordEndMacro {
+		if isBlankLine(peek) && peek(skipWhitespace(peek)) != 0 {
+			skipLine(peek, consume)
+			continue
+		}
+
+		if ws := skipWhitespace(peek); peekMultiple(peek, ws, len(keywordEndMacro)) == keywordEndMacro {
+			consumeMultiple(consume, ws)
 			consumeMultiple(consume, len(keywordEndMacro))
 			break
 		}
@@ -190,13 +237,14 @@ func lexMacro(peek func(offset int) rune, consume func() rune) (*token.Macro, er
 			return nil, errors.New("unexpected EOF while parsing macro")
 		}
 
-		ins, err := lexInstruction(peek, consume)
+		ins, err := lexInstruction(peek, consume, pos)
 		if err != nil {
 			return nil, err
 		}
-		fmt.Println(ins.Opcode)
 		if ins.Label != "" {
 			return nil, errors.New("macros cannot have labels in the macro body")
+		} else if ins.Opcode == "" {
+			return nil, errors.New("macros cannot contain @ declarations")
 		}
 		instructions = append(instructions, ins)
 
@@ -212,7 +260,7 @@ func lexMacro(peek func(offset int) rune, consume func() rune) (*token.Macro, er
 	}, nil
 }
 
-func lexSubroutine(peek func(offset int) rune, consume func() rune) (*token.Subroutine, error) {
+func lexSubroutine(peek func(offset int) rune, consume func() rune, pos func() token.Position) (*token.Subroutine, error) {
 
 	const keywordEndSubroutine = "@endsubroutine"
 
@@ -228,7 +276,6 @@ func lexSubroutine(peek func(offset int) rune, consume func() rune) (*token.Subr
 	var label []rune
 
 	for peek(0) != ':' {
-		fmt.Println(string(peek(0)))
 		if isValidIdentifier(peek(0)) {
 			label = append(label, consume())
 		} else if peek(0) == 0 {
@@ -248,9 +295,13 @@ func lexSubroutine(peek func(offset int) rune, consume func() rune) (*token.Subr
 	var instructions []*token.Instruction
 
 	for {
-		fmt.Println(peekMultiple(peek, 0, len(keywordEndSubroutine)))
+		if isBlankLine(peek) && peek(skipWhitespace(peek)) != 0 {
+			skipLine(peek, consume)
+			continue
+		}
 
-		if peekMultiple(peek, 0, len(keywordEndSubroutine)) == keywordEndSubroutine {
+		if ws := skipWhitespace(peek); peekMultiple(peek, ws, len(keywordEndSubroutine)) == keywordEndSubroutine {
+			consumeMultiple(consume, ws)
 			consumeMultiple(consume, len(keywordEndSubroutine))
 			break
 		}
@@ -259,13 +310,14 @@ func lexSubroutine(peek func(offset int) rune, consume func() rune) (*token.Subr
 			return nil, errors.New("unexpected EOF while parsing subroutine")
 		}
 
-		ins, err := lexInstruction(peek, consume)
+		ins, err := lexInstruction(peek, consume, pos)
 		if err != nil {
 			return nil, err
 		}
-		fmt.Println(ins.Opcode)
 		if ins.Label != "" {
 			return nil, errors.New("subroutines cannot have labels in the subroutine body")
+		} else if ins.Opcode == "" {
+			return nil, errors.New("subroutines cannot contain @ declarations")
 		}
 		instructions = append(instructions, ins)
 
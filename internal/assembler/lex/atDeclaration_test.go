@@ -21,7 +21,7 @@ func Test_lexAtDeclaration(t *testing.T) {
 
     disp $0 $1 5`)
 
-	ins, err := Lex(input)
+	ins, err := Lex("", input)
 	if err != nil {
 		fmt.Println(err)
 	} else {
@@ -0,0 +1,90 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/lex/data.go
+
+package lex
+
+import (
+	"fmt"
+	"github.com/codemicro/chip8/internal/assembler/token"
+)
+
+const (
+	keywordByte  = "byte"
+	keywordWord  = "word"
+	keywordFill  = "fill"
+	keywordAlign = "align"
+)
+
+var dataDirectives = []string{keywordByte, keywordWord, keywordFill, keywordAlign}
+
+// lexData lexes the values of a data directive, up to the end of the line
+func lexData(peek func(offset int) rune, consume func() rune, pos func() token.Position, directive string) (*token.Data, error) {
+
+	if !isWhitespace(peek(0)) {
+		return nil, fmt.Errorf("expecting value in @%s", directive)
+	}
+
+	d := &token.Data{
+		Directive: directive,
+	}
+
+	for {
+		for isWhitespace(peek(0)) {
+			consume()
+		}
+
+		if x := peek(0); x == '\n' || x == 0 {
+			break
+		} else if x == ';' {
+			for peek(0) != '\n' && peek(0) != 0 {
+				consume()
+			}
+			break
+		}
+
+		val, err := lexValue(peek, consume, pos)
+		if err != nil {
+			return nil, err
+		}
+		if val.Type() == token.TypeRegister {
+			return nil, fmt.Errorf("@%s cannot take a register", directive)
+		}
+		d.Values = append(d.Values, val)
+	}
+
+	if len(d.Values) == 0 {
+		return nil, fmt.Errorf("expecting value in @%s", directive)
+	}
+
+	return d, nil
+}
+
+// parseBitmap parses a row of a sprite, written with a # for each set pixel and a . for each unset pixel, into a
+// value. Rows of up to 8 pixels are padded on the right to produce an 8-bit value, and rows of up to 16 pixels to
+// produce a 16-bit value.
+func parseBitmap(row []rune) (int, error) {
+	if len(row) > 16 {
+		return 0, fmt.Errorf("sprite row %#v is longer than 16 pixels", string(row))
+	}
+
+	width := 8
+	if len(row) > 8 {
+		width = 16
+	}
+
+	var n int
+	for _, r := range row {
+		n <<= 1
+		switch r {
+		case '#':
+			n |= 1
+		case '.':
+		default:
+			return 0, fmt.Errorf("disallowed character %#v in sprite row", string(r))
+		}
+	}
+
+	return n << (width - len(row)), nil
+}
@@ -0,0 +1,244 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/lex/expression.go
+
+package lex
+
+import (
+	"github.com/codemicro/chip8/internal/assembler"
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"strconv"
+	"strings"
+)
+
+// binaryOperators lists the binary operators in order of increasing precedence. Operators in the same group have the
+// same precedence.
+var binaryOperators = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// builtinFunctions lists the functions that can be called in an expression
+var builtinFunctions = map[string]bool{
+	"lo": true,
+	"hi": true,
+}
+
+// expressionParser is a recursive descent parser for constant expressions. src is the text of a single operand that
+// starts at position start.
+type expressionParser struct {
+	src   []rune
+	index int
+	start token.Position
+}
+
+// parseExpression parses src into an expression. If the expression is a single literal or label, the returned
+// expression is an *token.Operand of that type.
+func parseExpression(src []rune, start token.Position) (token.Expression, error) {
+	p := &expressionParser{
+		src:   src,
+		start: start,
+	}
+
+	e, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipWhitespace()
+	if p.index != len(p.src) {
+		return nil, p.errorf("unexpected %#v in expression", string(p.src[p.index]))
+	}
+
+	return e, nil
+}
+
+func (p *expressionParser) pos() token.Position {
+	x := p.start
+	x.Column += p.index
+	return x
+}
+
+func (p *expressionParser) errorf(format string, a ...interface{}) error {
+	return assembler.Errorf(p.pos(), format, a...)
+}
+
+func (p *expressionParser) peek() rune {
+	if p.index >= len(p.src) {
+		return 0
+	}
+	return p.src[p.index]
+}
+
+func (p *expressionParser) skipWhitespace() {
+	for isWhitespace(p.peek()) {
+		p.index += 1
+	}
+}
+
+// hasPrefix reports if the unparsed input starts with x
+func (p *expressionParser) hasPrefix(x string) bool {
+	return strings.HasPrefix(string(p.src[p.index:]), x)
+}
+
+func (p *expressionParser) parseBinary(level int) (token.Expression, error) {
+	if level == len(binaryOperators) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipWhitespace()
+		position := p.pos()
+
+		var operator string
+		for _, op := range binaryOperators[level] {
+			if p.hasPrefix(op) {
+				operator = op
+				break
+			}
+		}
+		if operator == "" {
+			return left, nil
+		}
+		p.index += len(operator)
+
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		left = &token.BinaryExpression{
+			Position: position,
+			Operator: operator,
+			Left:     left,
+			Right:    right,
+		}
+	}
+}
+
+func (p *expressionParser) parseUnary() (token.Expression, error) {
+	p.skipWhitespace()
+	position := p.pos()
+
+	if x := p.peek(); x == '-' || x == '~' {
+		p.index += 1
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &token.UnaryExpression{
+			Position: position,
+			Operator: string(x),
+			Operand:  operand,
+		}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (token.Expression, error) {
+	p.skipWhitespace()
+	position := p.pos()
+
+	switch x := p.peek(); {
+	case x == '(':
+		p.index += 1
+		e, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+		if p.peek() != ')' {
+			return nil, p.errorf("expecting )")
+		}
+		p.index += 1
+		return e, nil
+
+	case isDigit(x):
+		var buf []rune
+		for isValidIdentifier(p.peek()) {
+			buf = append(buf, p.peek())
+			p.index += 1
+		}
+		n, err := parseInteger(string(buf))
+		if err != nil {
+			return nil, assembler.Errorf(position, "invalid value %#v", string(buf))
+		}
+		return &token.Operand{
+			Position:    position,
+			OperandType: token.TypeValue,
+			Value:       n,
+		}, nil
+
+	case isCharacter(x):
+		var buf []rune
+		for isValidIdentifier(p.peek()) {
+			buf = append(buf, p.peek())
+			p.index += 1
+		}
+		name := string(buf)
+
+		if p.peek() != '(' {
+			return &token.Operand{
+				Position:    position,
+				OperandType: token.TypeLabel,
+				Label:       name,
+			}, nil
+		}
+
+		if !builtinFunctions[strings.ToLower(name)] {
+			return nil, assembler.Errorf(position, "unknown function %#v", name)
+		}
+		p.index += 1
+
+		arg, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+		if p.peek() != ')' {
+			return nil, p.errorf("expecting ) after argument to %s", name)
+		}
+		p.index += 1
+
+		return &token.CallExpression{
+			Position: position,
+			Function: strings.ToLower(name),
+			Argument: arg,
+		}, nil
+
+	case x == 0:
+		return nil, p.errorf("unexpected end of expression")
+
+	default:
+		return nil, p.errorf("unexpected %#v in expression", string(x))
+	}
+}
+
+// parseInteger parses an integer literal, which is hex if prefixed with 0x, binary if prefixed with 0b or denary
+// otherwise
+func parseInteger(x string) (int, error) {
+	x = strings.ToLower(x)
+
+	base := 10
+	if strings.HasPrefix(x, "0x") {
+		x = strings.TrimPrefix(x, "0x")
+		base = 16
+	} else if strings.HasPrefix(x, "0b") {
+		x = strings.TrimPrefix(x, "0b")
+		base = 2
+	}
+
+	n, err := strconv.ParseInt(x, base, 32)
+	return int(n), err
+}
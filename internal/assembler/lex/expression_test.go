@@ -0,0 +1,42 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/lex/expression_test.go
+
+package lex
+
+import (
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"testing"
+)
+
+func Test_parseExpression(t *testing.T) {
+	cases := map[string]string{
+		"1 + 2 * 3":        "(1 + (2 * 3))",
+		"(1 + 2) * 3":      "((1 + 2) * 3)",
+		"a | b ^ c & d":    "(a | (b ^ (c & d)))",
+		"1 << 2 + 3":       "(1 << (2 + 3))",
+		"10 - 4 - 3":       "((10 - 4) - 3)",
+		"-x % 0x10":        "(-x % 16)",
+		"~0b1 & lo(label)": "(~1 & lo(label))",
+		"HI(x + 1)":        "hi((x + 1))",
+		"label":            "label",
+	}
+
+	for input, want := range cases {
+		e, err := parseExpression([]rune(input), token.Position{})
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", input, err)
+			continue
+		}
+		if got := e.String(); got != want {
+			t.Errorf("%q: incorrect expression (got %s, want %s)", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"1 +", "(1", "1)", "foo(2)", "1 $ 2", "0xZZ"} {
+		if _, err := parseExpression([]rune(input), token.Position{}); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
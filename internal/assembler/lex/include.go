@@ -0,0 +1,123 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/lex/include.go
+
+package lex
+
+import (
+	"fmt"
+	"github.com/codemicro/chip8/internal/assembler"
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LexFile lexes the file at filename, recursively replacing each @include declaration with the tokens of the file it
+// names. Included files are looked for relative to the directory of the including file, then in each of includePaths
+// in order. Each file is only ever included once, and include cycles are reported as an error.
+func LexFile(filename string, includePaths []string) ([]token.Token, error) {
+	r := &includeResolver{
+		includePaths: includePaths,
+		included:     make(map[string]bool),
+	}
+	return r.lexFile(filename)
+}
+
+type includeResolver struct {
+	includePaths []string
+	included     map[string]bool
+	stack        []string // files currently being lexed, outermost first
+}
+
+func (r *includeResolver) lexFile(filename string) ([]token.Token, error) {
+
+	absFilename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.included[absFilename] {
+		return nil, nil
+	}
+	r.included[absFilename] = true
+
+	r.stack = append(r.stack, absFilename)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	fcont, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := Lex(filename, fcont)
+	if err != nil {
+		return nil, err
+	}
+
+	var o []token.Token
+	for _, tk := range tokens {
+		inc, ok := tk.(*token.Include)
+		if !ok {
+			o = append(o, tk)
+			continue
+		}
+
+		includeFilename, err := r.resolve(filepath.Dir(filename), inc.Filename)
+		if err != nil {
+			return nil, includeError(inc, fcont, err.Error())
+		}
+
+		if cycle := r.findCycle(includeFilename); cycle != nil {
+			return nil, includeError(inc, fcont, "include cycle: "+strings.Join(cycle, " -> "))
+		}
+
+		includedTokens, err := r.lexFile(includeFilename)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, includedTokens...)
+	}
+
+	return o, nil
+}
+
+// resolve finds the file referred to by an @include declaration in a file in the directory dir
+func (r *includeResolver) resolve(dir, filename string) (string, error) {
+	if filepath.IsAbs(filename) {
+		return filename, nil
+	}
+
+	for _, searchDir := range append([]string{dir}, r.includePaths...) {
+		candidate := filepath.Join(searchDir, filename)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot find included file %#v", filename)
+}
+
+// findCycle returns the chain of includes that leads back to filename if including filename from the file currently
+// being lexed would create an include cycle. Otherwise, it returns nil.
+func (r *includeResolver) findCycle(filename string) []string {
+	absFilename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil
+	}
+	for i, x := range r.stack {
+		if x == absFilename {
+			return append(append([]string{}, r.stack[i:]...), absFilename)
+		}
+	}
+	return nil
+}
+
+// includeError creates an error positioned at an @include declaration in the source src
+func includeError(inc *token.Include, src []byte, message string) error {
+	e := assembler.NewError(inc.Pos(), message)
+	e.SourceLine = assembler.SourceLine(src, inc.Line)
+	return e
+}
@@ -0,0 +1,82 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/lex/include_test.go
+
+package lex
+
+import (
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "c8asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	for name, cont := range files {
+		fname := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, []byte(cont), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func Test_LexFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"src/main.asm":   "@include consts.asm\n@include util.asm\n@include util.asm\n    clr\n",
+		"src/consts.asm": "@define answer 42\n",
+		"lib/util.asm":   "@include ../src/consts.asm\n    rtn\n",
+	})
+
+	tokens, err := LexFile(filepath.Join(dir, "src", "main.asm"), []string{filepath.Join(dir, "lib")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(tokens) != 3 {
+		t.Fatalf("incorrect number of tokens (got %d, want %d)", len(tokens), 3)
+	}
+
+	if d, ok := tokens[0].(*token.Define); !ok || filepath.Base(d.File) != "consts.asm" {
+		t.Errorf("token 0 should be a define from consts.asm, got %v from %s", tokens[0], tokens[0].Pos().File)
+	}
+
+	if ins, ok := tokens[1].(*token.Instruction); !ok || ins.Opcode != "rtn" || filepath.Base(ins.File) != "util.asm" {
+		t.Errorf("token 1 should be rtn from util.asm, got %v from %s", tokens[1], tokens[1].Pos().File)
+	}
+
+	if ins, ok := tokens[2].(*token.Instruction); !ok || ins.Opcode != "clr" || filepath.Base(ins.File) != "main.asm" {
+		t.Errorf("token 2 should be clr from main.asm, got %v from %s", tokens[2], tokens[2].Pos().File)
+	}
+}
+
+func Test_LexFileErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.asm":       "@include b.asm\n",
+		"b.asm":       "@include a.asm\n",
+		"missing.asm": "@include nowhere.asm\n",
+	})
+
+	_, err := LexFile(filepath.Join(dir, "a.asm"), nil)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected include cycle error, got %v", err)
+	}
+
+	_, err = LexFile(filepath.Join(dir, "missing.asm"), nil)
+	if err == nil || !strings.Contains(err.Error(), "nowhere.asm") || !strings.Contains(err.Error(), "missing.asm") {
+		t.Errorf("expected error naming the missing file and the including file, got %v", err)
+	}
+}
@@ -8,12 +8,13 @@ package lex
 import (
 	"errors"
 	"fmt"
+	"github.com/codemicro/chip8/internal/assembler"
 	"github.com/codemicro/chip8/internal/assembler/token"
 	"strconv"
 	"strings"
 )
 
-func lexInstruction(peek func(offset int) rune, consume func() rune) (*token.Instruction, error) {
+func lexInstruction(peek func(offset int) rune, consume func() rune, pos func() token.Position) (*token.Instruction, error) {
 
 	var ins token.Instruction
 
@@ -27,22 +28,36 @@ func lexInstruction(peek func(offset int) rune, consume func() rune) (*token.Ins
 	var buffer []rune
 	var state int
 
+	ins.Position = pos()
+
 lexLoop:
 	for peek(0) != 0 {
 
 		switch state {
 		case label:
-			if isWhitespace(peek(0)) {
-				consume()
-				ins.Label = string(buffer)
-				buffer = nil
-				state = opcode
-			} else if peek(0) == ':' && peek(1) == '\n' && isWhitespace(peek(2)) { // label, colon, newline format
-				consume()
-				consume()
-				consume()
+			if x := peek(0); isWhitespace(x) || x == ':' {
+				if x == ':' {
+					consume()
+				}
 				ins.Label = string(buffer)
 				buffer = nil
+
+				// a label may be on a line of its own, followed by the opcode on the next non-blank line
+				for isBlankLine(peek) && peek(skipWhitespace(peek)) != 0 {
+					skipLine(peek, consume)
+				}
+				for isWhitespace(peek(0)) {
+					consume()
+				}
+				if x := peek(0); x == 0 {
+					return nil, fmt.Errorf("expecting opcode after label %#v", ins.Label)
+				} else if x == '@' {
+					// labelled data directive, which is lexed by the caller
+					return &ins, nil
+				}
+				if ins.Label == "" {
+					ins.Position = pos()
+				}
 				state = opcode
 			} else if !isValidIdentifier(peek(0)) {
 				return nil, fmt.Errorf("disallowed character %#v in label", string(peek(0)))
@@ -70,7 +85,8 @@ lexLoop:
 			if x := peek(0); x == ';' {
 				consume()
 				state = comment
-			} else if  x == '\n' {
+				continue lexLoop
+			} else if x == '\n' {
 				consume()
 				break lexLoop
 			}
@@ -80,12 +96,11 @@ lexLoop:
 				continue lexLoop
 			}
 
-			if !(ins.Arg1 == nil || ins.Arg2 == nil) {
-				state = comment
-				break
+			if !(ins.Arg1 == nil || ins.Arg2 == nil || ins.Arg3 == nil) {
+				return nil, errors.New("too many operands, expecting at most 3")
 			}
 
-			opa, err := lexValue(peek, consume)
+			opa, err := lexValue(peek, consume, pos)
 			if err != nil {
 				return nil, err
 			}
@@ -94,6 +109,8 @@ lexLoop:
 				ins.Arg1 = opa
 			} else if ins.Arg2 == nil {
 				ins.Arg2 = opa
+			} else if ins.Arg3 == nil {
+				ins.Arg3 = opa
 			}
 
 		case comment:
@@ -110,55 +127,89 @@ lexLoop:
 
 func lexOpcode(peek func(offset int) rune, consume func() rune) (string, error) {
 	var o string
-	for len(o) <= 4 {
+	for {
 		if x := peek(0); isWhitespace(x) || x == '\n' || x == 0 {
 			if len(o) == 0 {
 				return "", errors.New("expecting opcode")
 			}
 			return o, nil
+		} else if !isValidIdentifier(x) {
+			return "", fmt.Errorf("disallowed character %#v in opcode", string(x))
 		}
 		o += string(consume())
 	}
-	return o, nil
 }
 
-func lexValue(peek func(offset int) rune, consume func() rune) (*token.Operand, error) {
+// lexValue lexes a single operand. Operands end at whitespace, except within parentheses, so expressions containing
+// spaces must be parenthesised.
+func lexValue(peek func(offset int) rune, consume func() rune, pos func() token.Position) (*token.Operand, error) {
 
 	var buf []rune
+	var depth int
+	start := pos()
 
 	for {
-		if x := peek(0); isWhitespace(x) || x == '\n' || (x == 0 && len(buf) != 0) {
+		x := peek(0)
+		if depth == 0 && (isWhitespace(x) || x == '\n' || x == ';' || (x == 0 && len(buf) != 0)) {
 			break
-		} else if x == 0 {
+		} else if x == 0 || x == '\n' {
+			if depth != 0 {
+				return nil, errors.New("unclosed parenthesis in value")
+			}
 			return nil, errors.New("EOF when parsing value")
 		}
+
+		if x == '(' {
+			depth += 1
+		} else if x == ')' {
+			depth -= 1
+			if depth < 0 {
+				return nil, errors.New("unexpected ) in value")
+			}
+		}
+
 		buf = append(buf, consume())
 	}
 
-	instr := strings.ToLower(string(buf))
-
-	base := 10
-	opType := token.TypeValue
-	if strings.HasPrefix(instr, "$") {
+	if buf[0] == '$' {
 		// is a register
-		instr = strings.TrimPrefix(instr, "$")
-		base = 16
-		opType = token.TypeRegister
-	} else if strings.HasPrefix(instr, "0x") {
-		instr = strings.TrimPrefix(instr, "0x")
-		base = 16
-	} else if strings.HasPrefix(instr, "0b") {
-		instr = strings.TrimPrefix(instr, "0b")
-		base = 2
+		n, err := strconv.ParseInt(string(buf[1:]), 16, 32)
+		if err != nil {
+			return nil, assembler.Errorf(start, "invalid register %#v", string(buf))
+		}
+		return &token.Operand{
+			Position:    start,
+			OperandType: token.TypeRegister,
+			Value:       int(n),
+		}, nil
+	}
+
+	if buf[0] == '#' || buf[0] == '.' {
+		// is a sprite row
+		n, err := parseBitmap(buf)
+		if err != nil {
+			return nil, assembler.NewError(start, err.Error())
+		}
+		return &token.Operand{
+			Position:    start,
+			OperandType: token.TypeValue,
+			Value:       n,
+		}, nil
 	}
 
-	n, err := strconv.ParseInt(instr, base, 32)
+	expr, err := parseExpression(buf, start)
 	if err != nil {
 		return nil, err
 	}
 
+	if op, ok := expr.(*token.Operand); ok {
+		// a single literal or label
+		return op, nil
+	}
+
 	return &token.Operand{
-		OperandType: opType,
-		Value:       int(n),
+		Position:    start,
+		OperandType: token.TypeExpression,
+		Expression:  expr,
 	}, nil
 }
@@ -7,6 +7,7 @@ package lex
 
 import (
 	"fmt"
+	"github.com/codemicro/chip8/internal/assembler/token"
 	"testing"
 )
 
@@ -31,7 +32,11 @@ func Test_lexInstruction(t *testing.T) {
 		return rune(input[index-1])
 	}
 
-	ins, err := lexInstruction(peek, consume)
+	pos := func() token.Position {
+		return token.Position{}
+	}
+
+	ins, err := lexInstruction(peek, consume, pos)
 	if err != nil {
 		fmt.Println(err)
 	} else {
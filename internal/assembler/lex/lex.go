@@ -6,14 +6,19 @@
 package lex
 
 import (
+	"errors"
 	"fmt"
+	"github.com/codemicro/chip8/internal/assembler"
 	"github.com/codemicro/chip8/internal/assembler/token"
 )
 
-func Lex(input []byte) ([]token.Token, error) {
+// Lex splits input into tokens. filename is used in the positions of tokens and in errors, and may be empty. Errors
+// returned are of type *assembler.Error.
+func Lex(filename string, input []byte) ([]token.Token, error) {
 
 	inputLength := len(input)
 	var index int
+	line, column := 1, 1
 
 	peek := func(offset int) rune {
 		if index+offset >= inputLength {
@@ -27,32 +32,77 @@ func Lex(input []byte) ([]token.Token, error) {
 			return 0
 		}
 		index += 1
-		return rune(input[index-1])
+		r := rune(input[index-1])
+		if r == '\n' {
+			line += 1
+			column = 1
+		} else {
+			column += 1
+		}
+		return r
+	}
+
+	pos := func() token.Position {
+		return token.Position{
+			File:   filename,
+			Line:   line,
+			Column: column,
+		}
+	}
+
+	// errors from the lex functions are raised at the point that lexing failed, so the current position is used as
+	// the position of the error
+	positionedError := func(err error) error {
+		var e *assembler.Error
+		if !errors.As(err, &e) {
+			e = assembler.NewError(pos(), err.Error())
+		}
+		if e.SourceLine == "" {
+			e.SourceLine = assembler.SourceLine(input, e.Line)
+		}
+		return e
 	}
 
 	var tokens []token.Token
 
 	for index < inputLength {
-		if peekMultiple(peek, 0, 2) == "\n\n" {
-			fmt.Println("Hi!")
-			consumeMultiple(consume, 2)
+		if isBlankLine(peek) {
+			skipLine(peek, consume)
+			continue
 		}
-		if peek(0) == '@' {
-			tk, err := lexAtDeclaration(peek, consume)
+
+		if peek(skipWhitespace(peek)) == '@' {
+			for isWhitespace(peek(0)) {
+				consume()
+			}
+			tk, err := lexAtDeclaration(peek, consume, pos)
 			if err != nil {
-				return nil, err
+				return nil, positionedError(err)
 			}
 			tokens = append(tokens, tk)
-		} else if peek(0) == ';' {
-			for peek(0) != '\n' {
-				consume()
-			}
-			consume()
 		} else {
-			tk, err := lexInstruction(peek, consume)
+			tk, err := lexInstruction(peek, consume, pos)
 			if err != nil {
-				return nil, err
+				return nil, positionedError(err)
+			}
+
+			if tk.Opcode == "" {
+				// lexInstruction stops after a label that is followed by an @ declaration, which must be a data
+				// directive
+				dtk, err := lexAtDeclaration(peek, consume, pos)
+				if err != nil {
+					return nil, positionedError(err)
+				}
+				data, ok := dtk.(*token.Data)
+				if !ok {
+					return nil, positionedError(assembler.Errorf(tk.Pos(), "label %#v must be followed by an instruction or data directive", tk.Label))
+				}
+				data.Label = tk.Label
+				data.Position = tk.Position
+				tokens = append(tokens, data)
+				continue
 			}
+
 			tokens = append(tokens, tk)
 		}
 	}
@@ -87,7 +137,31 @@ func isCharacter(r rune) bool {
 }
 
 func isWhitespace(r rune) bool {
-	return r == ' ' || r == '\t'
+	return r == ' ' || r == '\t' || r == '\r'
+}
+
+// skipWhitespace returns the offset of the first non-whitespace character from the current position
+func skipWhitespace(peek func(offset int) rune) int {
+	var offset int
+	for isWhitespace(peek(offset)) {
+		offset += 1
+	}
+	return offset
+}
+
+// isBlankLine returns true if the line starting at the current position is empty, contains only whitespace or
+// contains only a comment
+func isBlankLine(peek func(offset int) rune) bool {
+	x := peek(skipWhitespace(peek))
+	return x == '\n' || x == ';' || x == 0
+}
+
+// skipLine consumes up to and including the next newline
+func skipLine(peek func(offset int) rune, consume func() rune) {
+	for peek(0) != '\n' && peek(0) != 0 {
+		consume()
+	}
+	consume()
 }
 
 func peekMultiple(peek func(offset int) rune, offset, runLength int) string {
@@ -0,0 +1,130 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/lex/lex_test.go
+
+package lex
+
+import (
+	"github.com/codemicro/chip8/internal/assembler"
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"testing"
+)
+
+func Test_Lex(t *testing.T) {
+	input := []byte(`; leading comment
+main:
+    clr
+
+    set $0 3 ; trailing comment
+	set $1 0x1F
+loop: disp $0 $1 5
+end jmp 0b1000000000
+`)
+
+	tokens, err := Lex("", input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []struct {
+		label, opcode string
+		operands      int
+	}{
+		{"main", "clr", 0},
+		{"", "set", 2},
+		{"", "set", 2},
+		{"loop", "disp", 3},
+		{"end", "jmp", 1},
+	}
+
+	if len(tokens) != len(want) {
+		t.Fatalf("incorrect number of tokens (got %d, want %d)", len(tokens), len(want))
+	}
+
+	for i, tk := range tokens {
+		ins, ok := tk.(*token.Instruction)
+		if !ok {
+			t.Fatalf("token %d is not an instruction", i)
+		}
+
+		var operands int
+		for _, op := range []*token.Operand{ins.Arg1, ins.Arg2, ins.Arg3} {
+			if op != nil {
+				operands += 1
+			}
+		}
+
+		if ins.Label != want[i].label || ins.Opcode != want[i].opcode || operands != want[i].operands {
+			t.Errorf("token %d incorrect (got %q %q with %d operands, want %q %q with %d operands)", i, ins.Label,
+				ins.Opcode, operands, want[i].label, want[i].opcode, want[i].operands)
+		}
+	}
+
+	if v := tokens[4].(*token.Instruction).Arg1.Value; v != 0x200 {
+		t.Errorf("binary value parsed incorrectly (got %#x, want %#x)", v, 0x200)
+	}
+}
+
+func Test_LexPositions(t *testing.T) {
+	input := []byte("; comment\nmain:\n    set $0 3\n\tdisp $0 $1 5\n")
+
+	tokens, err := Lex("main.asm", input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := tokens[0].Pos(), (token.Position{File: "main.asm", Line: 2, Column: 1}); got != want {
+		t.Errorf("incorrect position for labelled instruction (got %+v, want %+v)", got, want)
+	}
+
+	disp := tokens[1].(*token.Instruction)
+	if got, want := disp.Pos(), (token.Position{File: "main.asm", Line: 4, Column: 2}); got != want {
+		t.Errorf("incorrect position for instruction (got %+v, want %+v)", got, want)
+	}
+	if got, want := disp.Arg3.Pos(), (token.Position{File: "main.asm", Line: 4, Column: 13}); got != want {
+		t.Errorf("incorrect position for operand (got %+v, want %+v)", got, want)
+	}
+
+	_, err = Lex("bad.asm", []byte("main:\n    set $0 3x\n"))
+	aerr, ok := err.(*assembler.Error)
+	if !ok {
+		t.Fatalf("expected *assembler.Error, got %#v", err)
+	}
+	if aerr.Line != 2 || aerr.Column != 12 || aerr.SourceLine != "    set $0 3x" {
+		t.Errorf("incorrect error position (got %d:%d %q)", aerr.Line, aerr.Column, aerr.SourceLine)
+	}
+}
+
+func Test_LexData(t *testing.T) {
+	input := []byte(`ball:
+    @byte .##..... #..#
+    @word 0x1234 main ; comment
+big @word ##.............#
+`)
+
+	tokens, err := Lex("", input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(tokens) != 3 {
+		t.Fatalf("incorrect number of tokens (got %d, want %d)", len(tokens), 3)
+	}
+
+	ball, ok := tokens[0].(*token.Data)
+	if !ok || ball.Label != "ball" || ball.Directive != "byte" || len(ball.Values) != 2 {
+		t.Fatalf("incorrect first token (got %v)", tokens[0])
+	}
+	if ball.Values[0].Value != 0x60 || ball.Values[1].Value != 0x90 {
+		t.Errorf("incorrect sprite row values (got %#x %#x, want 0x60 0x90)", ball.Values[0].Value, ball.Values[1].Value)
+	}
+
+	if d := tokens[1].(*token.Data); d.Label != "" || len(d.Values) != 2 || d.Values[1].Label != "main" {
+		t.Errorf("incorrect second token (got %v)", d)
+	}
+
+	if d := tokens[2].(*token.Data); d.Label != "big" || d.Values[0].Value != 0xC001 {
+		t.Errorf("incorrect third token (got %v)", d)
+	}
+}
@@ -0,0 +1,116 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/parse/data.go
+
+package parse
+
+import (
+	"fmt"
+	"github.com/codemicro/chip8/internal/assembler"
+	"github.com/codemicro/chip8/internal/assembler/token"
+)
+
+// checkDataOperands validates the number of values given to a data directive
+func checkDataOperands(d *token.Data) error {
+	n := len(d.Values)
+	switch d.Directive {
+	case "byte", "word":
+		if n == 0 {
+			return fmt.Errorf("@%s expects at least 1 value", d.Directive)
+		}
+	case "fill":
+		if n < 1 || n > 2 {
+			return fmt.Errorf("@fill expects a count and an optional value, got %d value(s)", n)
+		}
+	case "align":
+		if n != 1 {
+			return fmt.Errorf("@align expects 1 value, got %d", n)
+		}
+	default:
+		return fmt.Errorf("unknown data directive @%s", d.Directive)
+	}
+	return nil
+}
+
+// dataSize returns the number of bytes that a data directive placed at address will emit. The count of @fill and the
+// boundary of @align are resolved while label addresses are still being assigned, so they may only refer to defines
+// and labels that come before them.
+func dataSize(d *token.Data, address int, symbols *symbolTable) (int, error) {
+	if err := checkDataOperands(d); err != nil {
+		return 0, err
+	}
+
+	switch d.Directive {
+	case "byte":
+		return len(d.Values), nil
+	case "word":
+		return len(d.Values) * 2, nil
+	case "fill":
+		count, err := valueOperand("@fill", d.Values[0], 0xFFFF, symbols)
+		if err != nil {
+			return 0, err
+		}
+		return int(count), nil
+	case "align":
+		boundary, err := valueOperand("@align", d.Values[0], 0xFFFF, symbols)
+		if err != nil {
+			return 0, err
+		}
+		if boundary == 0 {
+			return 0, assembler.NewError(d.Values[0].Pos(), "@align boundary cannot be zero")
+		}
+		return alignmentPadding(address, int(boundary)), nil
+	}
+
+	return 0, nil
+}
+
+// alignmentPadding returns the number of bytes needed to move address forward to a multiple of boundary
+func alignmentPadding(address, boundary int) int {
+	return (boundary - address%boundary) % boundary
+}
+
+// encodeData returns the bytes emitted by a data directive placed at address
+func encodeData(d *token.Data, address int, symbols *symbolTable) ([]byte, error) {
+	size, err := dataSize(d, address, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	var o []byte
+
+	switch d.Directive {
+	case "byte":
+		for _, op := range d.Values {
+			n, err := valueOperand("@byte", op, 0xFF, symbols)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, byte(n))
+		}
+	case "word":
+		for _, op := range d.Values {
+			n, err := valueOperand("@word", op, 0xFFFF, symbols)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, byte(n>>8), byte(n))
+		}
+	case "fill":
+		var value uint16
+		if len(d.Values) == 2 {
+			value, err = valueOperand("@fill", d.Values[1], 0xFF, symbols)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for i := 0; i < size; i += 1 {
+			o = append(o, byte(value))
+		}
+	case "align":
+		o = make([]byte, size)
+	}
+
+	return o, nil
+}
@@ -0,0 +1,123 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/parse/expression.go
+
+package parse
+
+import (
+	"errors"
+	"github.com/codemicro/chip8/internal/assembler"
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"math"
+)
+
+// evaluate calculates the value of a constant expression, resolving any labels and defines with symbols
+func evaluate(e token.Expression, symbols *symbolTable) (int, error) {
+	var value int
+
+	switch e := e.(type) {
+	case *token.Operand:
+		switch e.Type() {
+		case token.TypeValue:
+			return e.Value, nil
+		case token.TypeLabel:
+			v, err := symbols.resolve(e.Label)
+			if err != nil {
+				var aerr *assembler.Error
+				if errors.As(err, &aerr) {
+					// error in the value of a define
+					return 0, err
+				}
+				return 0, assembler.NewError(e.Pos(), err.Error())
+			}
+			return v, nil
+		case token.TypeExpression:
+			return evaluate(e.Expression, symbols)
+		default:
+			return 0, assembler.Errorf(e.Pos(), "%s cannot be used in an expression", e.String())
+		}
+
+	case *token.UnaryExpression:
+		x, err := evaluate(e.Operand, symbols)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Operator {
+		case "-":
+			value = -x
+		case "~":
+			value = ^x
+		default:
+			return 0, assembler.Errorf(e.Pos(), "unknown operator %s", e.Operator)
+		}
+
+	case *token.BinaryExpression:
+		left, err := evaluate(e.Left, symbols)
+		if err != nil {
+			return 0, err
+		}
+		right, err := evaluate(e.Right, symbols)
+		if err != nil {
+			return 0, err
+		}
+
+		switch e.Operator {
+		case "+":
+			value = left + right
+		case "-":
+			value = left - right
+		case "*":
+			value = left * right
+		case "/", "%":
+			if right == 0 {
+				return 0, assembler.NewError(e.Pos(), "division by zero")
+			}
+			if e.Operator == "/" {
+				value = left / right
+			} else {
+				value = left % right
+			}
+		case "&":
+			value = left & right
+		case "|":
+			value = left | right
+		case "^":
+			value = left ^ right
+		case "<<", ">>":
+			if right < 0 || right > 31 {
+				return 0, assembler.Errorf(e.Pos(), "shift of %d is out of range (expecting 0 to 31)", right)
+			}
+			if e.Operator == "<<" {
+				value = left << uint(right)
+			} else {
+				value = left >> uint(right)
+			}
+		default:
+			return 0, assembler.Errorf(e.Pos(), "unknown operator %s", e.Operator)
+		}
+
+	case *token.CallExpression:
+		x, err := evaluate(e.Argument, symbols)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Function {
+		case "lo":
+			value = x & 0xFF
+		case "hi":
+			value = (x >> 8) & 0xFF
+		default:
+			return 0, assembler.Errorf(e.Pos(), "unknown function %#v", e.Function)
+		}
+
+	default:
+		return 0, errors.New("unknown expression type")
+	}
+
+	if value > math.MaxInt32 || value < math.MinInt32 {
+		return 0, assembler.NewError(e.Pos(), "expression overflows")
+	}
+
+	return value, nil
+}
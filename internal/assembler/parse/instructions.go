@@ -0,0 +1,199 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/parse/instructions.go
+
+package parse
+
+import (
+	"fmt"
+	"github.com/codemicro/chip8/internal/assembler"
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"github.com/codemicro/chip8/internal/instructions"
+)
+
+// encodeInstruction validates the operands of an instruction and returns its big-endian encoding, which is two bytes
+// long for all instructions except `idxl`. Labels and defines used as values are resolved using symbols.
+func encodeInstruction(ins *token.Instruction, symbols *symbolTable) ([]byte, error) {
+	def, found := instructions.Lookup(ins.Opcode)
+	if !found {
+		return nil, fmt.Errorf("unknown opcode %#v", ins.Opcode)
+	}
+
+	operands := collectOperands(ins)
+
+	checkCount := func(min, max int) error {
+		if n := len(operands); n < min || n > max {
+			if min == max {
+				return fmt.Errorf("%s expects %d operand(s), got %d", ins.Opcode, min, n)
+			}
+			return fmt.Errorf("%s expects between %d and %d operands, got %d", ins.Opcode, min, max, n)
+		}
+		return nil
+	}
+
+	opcode := def.Base
+	var address uint16 // second word of FormatLongAddress instructions
+
+	switch def.Format {
+	case instructions.FormatNone:
+		if err := checkCount(0, 0); err != nil {
+			return nil, err
+		}
+
+	case instructions.FormatAddress:
+		if err := checkCount(1, 1); err != nil {
+			return nil, err
+		}
+		nnn, err := valueOperand(ins.Opcode, operands[0], 0xFFF, symbols)
+		if err != nil {
+			return nil, err
+		}
+		opcode |= nnn
+
+	case instructions.FormatRegister:
+		if err := checkCount(1, 1); err != nil {
+			return nil, err
+		}
+		x, err := registerOperand(ins.Opcode, operands[0])
+		if err != nil {
+			return nil, err
+		}
+		opcode |= x << 8
+
+	case instructions.FormatLongAddress:
+		if err := checkCount(1, 1); err != nil {
+			return nil, err
+		}
+		nnnn, err := valueOperand(ins.Opcode, operands[0], 0xFFFF, symbols)
+		if err != nil {
+			return nil, err
+		}
+		address = nnnn
+
+	case instructions.FormatPlanes:
+		if err := checkCount(1, 1); err != nil {
+			return nil, err
+		}
+		n, err := valueOperand(ins.Opcode, operands[0], 0x3, symbols)
+		if err != nil {
+			return nil, err
+		}
+		opcode |= n << 8
+
+	case instructions.FormatNibble:
+		if err := checkCount(1, 1); err != nil {
+			return nil, err
+		}
+		n, err := valueOperand(ins.Opcode, operands[0], 0xF, symbols)
+		if err != nil {
+			return nil, err
+		}
+		opcode |= n
+
+	case instructions.FormatRegisterConst:
+		if err := checkCount(2, 2); err != nil {
+			return nil, err
+		}
+		x, err := registerOperand(ins.Opcode, operands[0])
+		if err != nil {
+			return nil, err
+		}
+		nn, err := valueOperand(ins.Opcode, operands[1], 0xFF, symbols)
+		if err != nil {
+			return nil, err
+		}
+		opcode |= x<<8 | nn
+
+	case instructions.FormatRegisterReg, instructions.FormatRegisterOptReg:
+		if def.Format == instructions.FormatRegisterOptReg {
+			if err := checkCount(1, 2); err != nil {
+				return nil, err
+			}
+			if len(operands) == 1 {
+				operands = append(operands, operands[0])
+			}
+		} else if err := checkCount(2, 2); err != nil {
+			return nil, err
+		}
+		x, err := registerOperand(ins.Opcode, operands[0])
+		if err != nil {
+			return nil, err
+		}
+		y, err := registerOperand(ins.Opcode, operands[1])
+		if err != nil {
+			return nil, err
+		}
+		opcode |= x<<8 | y<<4
+
+	case instructions.FormatRegisterRegNib:
+		if err := checkCount(3, 3); err != nil {
+			return nil, err
+		}
+		x, err := registerOperand(ins.Opcode, operands[0])
+		if err != nil {
+			return nil, err
+		}
+		y, err := registerOperand(ins.Opcode, operands[1])
+		if err != nil {
+			return nil, err
+		}
+		n, err := valueOperand(ins.Opcode, operands[2], 0xF, symbols)
+		if err != nil {
+			return nil, err
+		}
+		opcode |= x<<8 | y<<4 | n
+	}
+
+	if def.Size() == 4 {
+		return []byte{byte(opcode >> 8), byte(opcode), byte(address >> 8), byte(address)}, nil
+	}
+	return []byte{byte(opcode >> 8), byte(opcode)}, nil
+}
+
+// instructionSize returns the number of bytes an instruction will be encoded as
+func instructionSize(ins *token.Instruction) int {
+	if def, found := instructions.Lookup(ins.Opcode); found {
+		return def.Size()
+	}
+	// unknown opcodes are reported when the instruction is encoded
+	return 2
+}
+
+// collectOperands returns the non-nil operands of an instruction, in order
+func collectOperands(ins *token.Instruction) []*token.Operand {
+	var o []*token.Operand
+	for _, op := range []*token.Operand{ins.Arg1, ins.Arg2, ins.Arg3} {
+		if op != nil {
+			o = append(o, op)
+		}
+	}
+	return o
+}
+
+// registerOperand checks that op refers to one of V0 to VF and returns the register number
+func registerOperand(opcode string, op *token.Operand) (uint16, error) {
+	if op.Type() != token.TypeRegister {
+		return 0, assembler.Errorf(op.Pos(), "%s expects a register, got %s", opcode, op.String())
+	}
+	if op.Value < 0 || op.Value > 0xF {
+		return 0, assembler.Errorf(op.Pos(), "%s: unknown register %s", opcode, op.String())
+	}
+	return uint16(op.Value), nil
+}
+
+// valueOperand evaluates op, which may be a constant, label, define or expression, and checks that the result is
+// between zero and max inclusive
+func valueOperand(opcode string, op *token.Operand, max int, symbols *symbolTable) (uint16, error) {
+	if op.Type() == token.TypeRegister {
+		return 0, assembler.Errorf(op.Pos(), "%s expects a value, got %s", opcode, op.String())
+	}
+	value, err := evaluate(op, symbols)
+	if err != nil {
+		return 0, err
+	}
+	if value < 0 || value > max {
+		return 0, assembler.Errorf(op.Pos(), "%s: value %d out of range (expecting 0 to %d)", opcode, value, max)
+	}
+	return uint16(value), nil
+}
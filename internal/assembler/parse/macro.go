@@ -0,0 +1,153 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/parse/macro.go
+
+package parse
+
+import (
+	"fmt"
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"github.com/codemicro/chip8/internal/instructions"
+	"strings"
+)
+
+// maxMacroDepth is the maximum number of macro invocations that can be nested inside one another before expansion is
+// abandoned. Exceeding it almost certainly means a macro is (indirectly) invoking itself.
+const maxMacroDepth = 16
+
+// expandMacros removes macro declarations from the token stream and replaces every invocation of a macro with a copy
+// of its body, with the macro's arguments substituted in.
+func expandMacros(tokens []token.Token) ([]token.Token, error) {
+
+	macros := make(map[string]*token.Macro)
+
+	for _, tk := range tokens {
+		macro, ok := tk.(*token.Macro)
+		if !ok {
+			continue
+		}
+
+		name := strings.ToLower(macro.Label)
+		if _, found := instructions.Lookup(name); found {
+			return nil, errorAt(macro, fmt.Errorf("macro %#v has the same name as an instruction", macro.Label))
+		}
+		if _, found := macros[name]; found {
+			return nil, errorAt(macro, fmt.Errorf("duplicate macro %#v", macro.Label))
+		}
+
+		seen := make(map[string]bool)
+		for _, arg := range macro.Arguments {
+			if seen[arg.Label] {
+				return nil, errorAt(macro, fmt.Errorf("duplicate argument %#v in macro %#v", arg.Label, macro.Label))
+			}
+			seen[arg.Label] = true
+		}
+
+		macros[name] = macro
+	}
+
+	var o []token.Token
+
+	for _, tk := range tokens {
+		switch tk := tk.(type) {
+		case *token.Macro:
+			continue
+		case *token.Instruction:
+			expanded, err := expandInstruction(tk, macros, 0)
+			if err != nil {
+				return nil, errorAt(tk, err)
+			}
+			for _, ins := range expanded {
+				o = append(o, ins)
+			}
+		case *token.Subroutine:
+			sr := &token.Subroutine{Position: tk.Position, Label: tk.Label}
+			for _, ins := range tk.Instructions {
+				expanded, err := expandInstruction(ins, macros, 0)
+				if err != nil {
+					return nil, errorAt(ins, err)
+				}
+				sr.Instructions = append(sr.Instructions, expanded...)
+			}
+			o = append(o, sr)
+		default:
+			o = append(o, tk)
+		}
+	}
+
+	return o, nil
+}
+
+// expandInstruction returns ins unchanged if it is not a macro invocation. Otherwise, it returns the fully expanded
+// body of the invoked macro, with any label on the invocation moved to the first instruction of the body. Expanded
+// instructions take the position of the invocation.
+func expandInstruction(ins *token.Instruction, macros map[string]*token.Macro, depth int) ([]*token.Instruction, error) {
+
+	macro, found := macros[ins.Opcode]
+	if !found {
+		return []*token.Instruction{ins}, nil
+	}
+
+	if depth >= maxMacroDepth {
+		return nil, fmt.Errorf("macro expansion exceeded maximum depth of %d (is %#v recursive?)", maxMacroDepth, macro.Label)
+	}
+
+	operands := collectOperands(ins)
+	if len(operands) != len(macro.Arguments) {
+		return nil, fmt.Errorf("macro %#v expects %d argument(s), got %d", macro.Label, len(macro.Arguments), len(operands))
+	}
+
+	substitutions := make(map[string]*token.Operand)
+	for i, arg := range macro.Arguments {
+		op := operands[i]
+		if arg.ArgumentType == token.TypeRegister && op.Type() != token.TypeRegister {
+			return nil, fmt.Errorf("macro %#v argument %#v expects a register, got %s", macro.Label, arg.Label, op.String())
+		} else if arg.ArgumentType == token.TypeValue && op.Type() == token.TypeRegister {
+			return nil, fmt.Errorf("macro %#v argument %#v expects a value, got %s", macro.Label, arg.Label, op.String())
+		}
+		substitutions[arg.Label] = op
+	}
+
+	substitute := func(op *token.Operand) *token.Operand {
+		if op == nil {
+			return nil
+		}
+		// arguments may also be used inside expressions
+		return token.MapExpression(op, func(leaf *token.Operand) *token.Operand {
+			if leaf.Type() == token.TypeLabel {
+				if x, found := substitutions[leaf.Label]; found {
+					return x
+				}
+			}
+			return leaf
+		}).(*token.Operand)
+	}
+
+	var o []*token.Instruction
+
+	for _, bodyIns := range macro.Instructions {
+		x := &token.Instruction{
+			Position: ins.Position,
+			Opcode:   bodyIns.Opcode,
+			Arg1:     substitute(bodyIns.Arg1),
+			Arg2:     substitute(bodyIns.Arg2),
+			Arg3:     substitute(bodyIns.Arg3),
+		}
+
+		expanded, err := expandInstruction(x, macros, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, expanded...)
+	}
+
+	if ins.Label != "" {
+		if len(o) == 0 {
+			return nil, fmt.Errorf("label %#v cannot be attached to empty macro %#v", ins.Label, macro.Label)
+		}
+		o[0].Label = ins.Label
+	}
+
+	return o, nil
+}
@@ -5,8 +5,70 @@
 
 package parse
 
-import "github.com/codemicro/chip8/internal/assembler/token"
+import (
+	"errors"
+	"fmt"
+	"github.com/codemicro/chip8/internal/assembler"
+	"github.com/codemicro/chip8/internal/assembler/token"
+)
 
-func Parse(tokens []token.Token) ([]byte, error) {
-	return nil, nil
+// Options controls optional behaviour of Parse
+type Options struct {
+	// StripUnusedSubroutines removes subroutines that are never referenced from the output.
+	StripUnusedSubroutines bool
+}
+
+// Parse assembles a stream of tokens into CHIP-8 bytecode, with each instruction encoded as a big-endian 16-bit
+// opcode (or two, for `idxl`). options may be nil, in which case the defaults are used. Errors returned are of type *assembler.Error.
+func Parse(tokens []token.Token, options *Options) ([]byte, error) {
+
+	if options == nil {
+		options = new(Options)
+	}
+
+	tokens, err := expandMacros(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens = layoutSubroutines(tokens, options.StripUnusedSubroutines)
+
+	symbols, err := buildSymbolTable(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	var output []byte
+
+	for _, tk := range tokens {
+		switch tk := tk.(type) {
+		case *token.Instruction:
+			encoded, err := encodeInstruction(tk, symbols)
+			if err != nil {
+				return nil, errorAt(tk, err)
+			}
+			output = append(output, encoded...)
+		case *token.Data:
+			data, err := encodeData(tk, programStart+len(output), symbols)
+			if err != nil {
+				return nil, errorAt(tk, err)
+			}
+			output = append(output, data...)
+		case *token.Define:
+			// defines are resolved when building the symbol table
+		default:
+			return nil, errorAt(tk, fmt.Errorf("unsupported token %#v", tk.String()))
+		}
+	}
+
+	return output, nil
+}
+
+// errorAt converts err into an *assembler.Error positioned at tk, unless it already is one
+func errorAt(tk token.Token, err error) error {
+	var e *assembler.Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return assembler.NewError(tk.Pos(), err.Error())
 }
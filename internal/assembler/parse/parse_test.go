@@ -0,0 +1,406 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/parse/parse_test.go
+
+package parse
+
+import (
+	"bytes"
+	"github.com/codemicro/chip8/internal/assembler/token"
+	"strings"
+	"testing"
+)
+
+func reg(n int) *token.Operand { return &token.Operand{OperandType: token.TypeRegister, Value: n} }
+func val(n int) *token.Operand { return &token.Operand{OperandType: token.TypeValue, Value: n} }
+
+func Test_Parse(t *testing.T) {
+	tokens := []token.Token{
+		&token.Instruction{Opcode: "clr"},
+		&token.Instruction{Opcode: "set", Arg1: reg(0xA), Arg2: val(0x2C)},
+		&token.Instruction{Opcode: "idx", Arg1: val(0x32A)},
+		&token.Instruction{Opcode: "disp", Arg1: reg(0), Arg2: reg(1), Arg3: val(5)},
+		&token.Instruction{Opcode: "sum", Arg1: reg(3), Arg2: reg(4)},
+		&token.Instruction{Opcode: "rsh", Arg1: reg(2)},
+		&token.Instruction{Opcode: "save", Arg1: reg(0xF)},
+		&token.Instruction{Opcode: "jmp", Arg1: val(0x200)},
+	}
+
+	want := []byte{
+		0x00, 0xE0,
+		0x6A, 0x2C,
+		0xA3, 0x2A,
+		0xD0, 0x15,
+		0x83, 0x44,
+		0x82, 0x26,
+		0xFF, 0x65,
+		0x12, 0x00,
+	}
+
+	got, err := Parse(tokens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode (got %x, want %x)", got, want)
+	}
+}
+
+func Test_ParseInvalid(t *testing.T) {
+	cases := map[string]*token.Instruction{
+		"unknown opcode":        {Opcode: "nop"},
+		"too few operands":      {Opcode: "set", Arg1: reg(0)},
+		"too many operands":     {Opcode: "clr", Arg1: reg(0)},
+		"value for register":    {Opcode: "char", Arg1: val(1)},
+		"register for value":    {Opcode: "jmp", Arg1: reg(1)},
+		"NNN out of range":      {Opcode: "jmp", Arg1: val(0x1000)},
+		"NN out of range":       {Opcode: "add", Arg1: reg(0), Arg2: val(0x100)},
+		"N out of range":        {Opcode: "disp", Arg1: reg(0), Arg2: reg(1), Arg3: val(0x10)},
+		"negative value":        {Opcode: "set", Arg1: reg(0), Arg2: val(-1)},
+		"register out of range": {Opcode: "dset", Arg1: reg(0x10)},
+	}
+
+	for name, ins := range cases {
+		if _, err := Parse([]token.Token{ins}, nil); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
+
+func label(name string) *token.Operand {
+	return &token.Operand{OperandType: token.TypeLabel, Label: name}
+}
+
+func Test_ParseLabels(t *testing.T) {
+	tokens := []token.Token{
+		&token.Instruction{Label: "main", Opcode: "call", Arg1: label("draw")},
+		&token.Instruction{Opcode: "jmp", Arg1: label("main")},
+		&token.Instruction{Label: "draw", Opcode: "idx", Arg1: label("draw")},
+		&token.Instruction{Opcode: "jmpo", Arg1: label("main")},
+	}
+
+	want := []byte{
+		0x22, 0x04,
+		0x12, 0x00,
+		0xA2, 0x04,
+		0xB2, 0x00,
+	}
+
+	got, err := Parse(tokens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode (got %x, want %x)", got, want)
+	}
+}
+
+func Test_ParseLabelErrors(t *testing.T) {
+	_, err := Parse([]token.Token{
+		&token.Instruction{Opcode: "jmp", Arg1: label("nowhere")},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "nowhere") {
+		t.Errorf("expected undefined label error naming the label, got %v", err)
+	}
+
+	_, err = Parse([]token.Token{
+		&token.Instruction{Label: "twice", Opcode: "clr"},
+		&token.Instruction{Label: "twice", Opcode: "clr"},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "twice") {
+		t.Errorf("expected duplicate label error naming the label, got %v", err)
+	}
+
+	_, err = Parse([]token.Token{
+		&token.Instruction{Label: "main", Opcode: "set", Arg1: reg(0), Arg2: label("main")},
+	}, nil)
+	if err == nil {
+		t.Error("expected error when using a label as an 8-bit constant, got none")
+	}
+}
+
+func Test_ParseMacros(t *testing.T) {
+	tokens := []token.Token{
+		&token.Define{Label: "height", Value: val(5)},
+		&token.Macro{
+			Label: "drawAt",
+			Arguments: []*token.Argument{
+				{ArgumentType: token.TypeRegister, Label: "x"},
+				{ArgumentType: token.TypeRegister, Label: "y"},
+				{ArgumentType: token.TypeValue, Label: "sprite"},
+			},
+			Instructions: []*token.Instruction{
+				{Opcode: "idx", Arg1: label("sprite")},
+				{Opcode: "disp", Arg1: label("x"), Arg2: label("y"), Arg3: label("height")},
+			},
+		},
+		&token.Instruction{Label: "main", Opcode: "drawat", Arg1: reg(1), Arg2: reg(2), Arg3: val(0x300)},
+		&token.Instruction{Opcode: "jmp", Arg1: label("main")},
+	}
+
+	want := []byte{
+		0xA3, 0x00,
+		0xD1, 0x25,
+		0x12, 0x00,
+	}
+
+	got, err := Parse(tokens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode (got %x, want %x)", got, want)
+	}
+}
+
+func Test_ParseMacroErrors(t *testing.T) {
+	inc := &token.Macro{
+		Label:        "inc",
+		Arguments:    []*token.Argument{{ArgumentType: token.TypeRegister, Label: "r"}},
+		Instructions: []*token.Instruction{{Opcode: "add", Arg1: label("r"), Arg2: val(1)}},
+	}
+	loop := &token.Macro{
+		Label:        "loop",
+		Instructions: []*token.Instruction{{Opcode: "loop"}},
+	}
+
+	cases := map[string][]token.Token{
+		"too few arguments":  {inc, &token.Instruction{Opcode: "inc"}},
+		"too many arguments": {inc, &token.Instruction{Opcode: "inc", Arg1: reg(0), Arg2: reg(1)}},
+		"value for register": {inc, &token.Instruction{Opcode: "inc", Arg1: val(0)}},
+		"recursive macro":    {loop, &token.Instruction{Opcode: "loop"}},
+		"duplicate macro":    {inc, inc},
+	}
+
+	for name, tokens := range cases {
+		if _, err := Parse(tokens, nil); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
+
+func Test_ParseSubroutines(t *testing.T) {
+	tokens := []token.Token{
+		&token.Subroutine{
+			Label:        "unused",
+			Instructions: []*token.Instruction{{Opcode: "clr"}},
+		},
+		&token.Instruction{Label: "main", Opcode: "call", Arg1: label("draw")},
+		&token.Subroutine{
+			Label:        "draw",
+			Instructions: []*token.Instruction{{Opcode: "call", Arg1: label("helper")}},
+		},
+		&token.Instruction{Opcode: "jmp", Arg1: label("main")},
+		&token.Subroutine{
+			Label:        "helper",
+			Instructions: []*token.Instruction{{Opcode: "clr"}, {Opcode: "rtn"}},
+		},
+	}
+
+	want := []byte{
+		0x22, 0x08, // call draw
+		0x12, 0x00, // jmp main
+		0x00, 0xE0, // unused
+		0x00, 0xEE,
+		0x22, 0x0C, // draw
+		0x00, 0xEE,
+		0x00, 0xE0, // helper
+		0x00, 0xEE,
+	}
+
+	got, err := Parse(tokens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode (got %x, want %x)", got, want)
+	}
+
+	want = []byte{
+		0x22, 0x04, // call draw
+		0x12, 0x00, // jmp main
+		0x22, 0x08, // draw
+		0x00, 0xEE,
+		0x00, 0xE0, // helper
+		0x00, 0xEE,
+	}
+
+	got, err = Parse(tokens, &Options{StripUnusedSubroutines: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode with unused subroutines stripped (got %x, want %x)", got, want)
+	}
+}
+
+func Test_ParseSubroutinesThroughDefines(t *testing.T) {
+	tokens := []token.Token{
+		&token.Define{Label: "f", Value: label("g")},
+		&token.Define{Label: "g", Value: label("draw")},
+		&token.Define{Label: "h", Value: label("unused")}, // never used, so doesn't keep unused
+		&token.Instruction{Label: "main", Opcode: "call", Arg1: label("f")},
+		&token.Instruction{Opcode: "jmp", Arg1: label("main")},
+		&token.Subroutine{
+			Label:        "unused",
+			Instructions: []*token.Instruction{{Opcode: "clr"}},
+		},
+		&token.Subroutine{
+			Label:        "draw",
+			Instructions: []*token.Instruction{{Opcode: "clr"}},
+		},
+	}
+
+	want := []byte{
+		0x22, 0x04, // call f
+		0x12, 0x00, // jmp main
+		0x00, 0xE0, // draw
+		0x00, 0xEE,
+	}
+
+	got, err := Parse(tokens, &Options{StripUnusedSubroutines: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode with unused subroutines stripped (got %x, want %x)", got, want)
+	}
+}
+
+func Test_ParseData(t *testing.T) {
+	tokens := []token.Token{
+		&token.Define{Label: "three", Value: val(3)},
+		&token.Instruction{Label: "main", Opcode: "idx", Arg1: label("sprite")},
+		&token.Data{Label: "table", Directive: "byte", Values: []*token.Operand{val(1), val(0xFF), label("three")}},
+		&token.Data{Directive: "word", Values: []*token.Operand{label("main"), val(0xBEEF)}},
+		&token.Data{Directive: "fill", Values: []*token.Operand{label("three"), val(0xAA)}},
+		&token.Data{Label: "sprite", Directive: "align", Values: []*token.Operand{val(8)}},
+		&token.Data{Directive: "byte", Values: []*token.Operand{val(0x60), val(0x90)}},
+		&token.Instruction{Opcode: "jmp", Arg1: label("table")},
+	}
+
+	want := []byte{
+		0xA2, 0x10, // idx sprite
+		0x01, 0xFF, 0x03, // table
+		0x02, 0x00, 0xBE, 0xEF,
+		0xAA, 0xAA, 0xAA,
+		0x00, 0x00, 0x00, 0x00, // align to 0x210
+		0x60, 0x90, // sprite
+		0x12, 0x02, // jmp table
+	}
+
+	got, err := Parse(tokens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode (got %x, want %x)", got, want)
+	}
+}
+
+func Test_ParseDataErrors(t *testing.T) {
+	cases := map[string]*token.Data{
+		"byte out of range":   {Directive: "byte", Values: []*token.Operand{val(0x100)}},
+		"word out of range":   {Directive: "word", Values: []*token.Operand{val(0x10000)}},
+		"fill without count":  {Directive: "fill"},
+		"fill with label":     {Label: "x", Directive: "fill", Values: []*token.Operand{label("x")}},
+		"align to zero":       {Directive: "align", Values: []*token.Operand{val(0)}},
+		"align with 2 values": {Directive: "align", Values: []*token.Operand{val(2), val(2)}},
+	}
+
+	for name, d := range cases {
+		if _, err := Parse([]token.Token{d}, nil); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
+
+func expr(e token.Expression) *token.Operand {
+	return &token.Operand{OperandType: token.TypeExpression, Expression: e}
+}
+
+func binary(op string, left, right token.Expression) token.Expression {
+	return &token.BinaryExpression{Operator: op, Left: left, Right: right}
+}
+
+func Test_ParseExpressions(t *testing.T) {
+	tokens := []token.Token{
+		&token.Define{Label: "width", Value: val(8)},
+		&token.Define{Label: "half", Value: expr(binary("/", label("width"), val(2)))},
+		&token.Instruction{Label: "main", Opcode: "set", Arg1: reg(0), Arg2: expr(binary("<<", label("half"), val(4)))},
+		&token.Instruction{Opcode: "set", Arg1: reg(1), Arg2: expr(&token.CallExpression{Function: "hi", Argument: label("end")})},
+		&token.Instruction{Opcode: "set", Arg1: reg(2), Arg2: expr(&token.CallExpression{Function: "lo", Argument: label("end")})},
+		&token.Instruction{Opcode: "jmp", Arg1: expr(binary("+", label("main"), val(2)))},
+		&token.Instruction{Opcode: "set", Arg1: reg(3), Arg2: expr(binary("&", &token.UnaryExpression{Operator: "~", Operand: val(1)}, val(0xFF)))},
+		&token.Instruction{Label: "end", Opcode: "disp", Arg1: reg(0), Arg2: reg(1), Arg3: expr(binary("-", label("half"), val(1)))},
+	}
+
+	want := []byte{
+		0x60, 0x40,
+		0x61, 0x02,
+		0x62, 0x0A,
+		0x12, 0x02,
+		0x63, 0xFE,
+		0xD0, 0x13,
+	}
+
+	got, err := Parse(tokens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode (got %x, want %x)", got, want)
+	}
+}
+
+func Test_ParseExpressionErrors(t *testing.T) {
+	cases := map[string][]token.Token{
+		"overflow": {
+			&token.Instruction{Opcode: "set", Arg1: reg(0), Arg2: expr(binary("+", val(200), val(100)))},
+		},
+		"division by zero": {
+			&token.Instruction{Opcode: "set", Arg1: reg(0), Arg2: expr(binary("/", val(1), val(0)))},
+		},
+		"register in expression": {
+			&token.Instruction{Opcode: "set", Arg1: reg(0), Arg2: expr(binary("+", reg(1), val(1)))},
+		},
+		"circular define": {
+			&token.Define{Label: "a", Value: label("b")},
+			&token.Define{Label: "b", Value: expr(binary("+", label("a"), val(1)))},
+			&token.Instruction{Opcode: "set", Arg1: reg(0), Arg2: label("a")},
+		},
+	}
+
+	for name, tokens := range cases {
+		if _, err := Parse(tokens, nil); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
+
+func Test_ParseLongInstructions(t *testing.T) {
+	tokens := []token.Token{
+		&token.Instruction{Opcode: "idxl", Arg1: label("data")},
+		&token.Instruction{Opcode: "plane", Arg1: val(3)},
+		&token.Instruction{Opcode: "loadr", Arg1: reg(1), Arg2: reg(4)},
+		&token.Instruction{Label: "end", Opcode: "jmp", Arg1: label("end")},
+		&token.Data{Label: "data", Directive: "byte", Values: []*token.Operand{val(0xAA)}},
+	}
+
+	want := []byte{
+		0xF0, 0x00, 0x02, 0x0A,
+		0xF3, 0x01,
+		0x51, 0x42,
+		0x12, 0x08,
+		0xAA,
+	}
+
+	got, err := Parse(tokens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("incorrect bytecode (got %x, want %x)", got, want)
+	}
+}
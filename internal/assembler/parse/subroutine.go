@@ -0,0 +1,118 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/parse/subroutine.go
+
+package parse
+
+import (
+	"github.com/codemicro/chip8/internal/assembler/token"
+)
+
+// layoutSubroutines moves the body of every subroutine to the end of the program, after all other instructions. The
+// first instruction of each subroutine is labelled with the subroutine's name so it can be used with `call`, and a
+// `rtn` is appended to any subroutine that doesn't already end with one.
+//
+// If stripUnused is true, subroutines that are never referenced by the main program or by another subroutine that is
+// itself referenced are omitted from the output.
+func layoutSubroutines(tokens []token.Token, stripUnused bool) []token.Token {
+
+	var program []token.Token
+	var subroutines []*token.Subroutine
+
+	for _, tk := range tokens {
+		if sr, ok := tk.(*token.Subroutine); ok {
+			subroutines = append(subroutines, sr)
+		} else {
+			program = append(program, tk)
+		}
+	}
+
+	if stripUnused {
+		subroutines = findUsedSubroutines(program, subroutines)
+	}
+
+	for _, sr := range subroutines {
+		body := make([]*token.Instruction, len(sr.Instructions))
+		copy(body, sr.Instructions)
+
+		if len(body) == 0 || body[len(body)-1].Opcode != "rtn" {
+			body = append(body, &token.Instruction{Position: sr.Position, Opcode: "rtn"})
+		}
+
+		first := *body[0]
+		first.Label = sr.Label
+		body[0] = &first
+
+		for _, ins := range body {
+			program = append(program, ins)
+		}
+	}
+
+	return program
+}
+
+// findUsedSubroutines returns the subroutines that are reachable from the main program, preserving their original
+// order. A subroutine referenced through a @define, or a chain of them, counts as reachable once the define is used.
+func findUsedSubroutines(program []token.Token, subroutines []*token.Subroutine) []*token.Subroutine {
+
+	byName := make(map[string]*token.Subroutine)
+	for _, sr := range subroutines {
+		byName[sr.Label] = sr
+	}
+
+	defines := make(map[string]*token.Operand)
+	for _, tk := range program {
+		if d, ok := tk.(*token.Define); ok {
+			defines[d.Label] = d.Value
+		}
+	}
+
+	used := make(map[string]bool)
+	followedDefines := make(map[string]bool)
+	var queue []string
+
+	var markReferences func(operands []*token.Operand)
+	markReferences = func(operands []*token.Operand) {
+		for _, op := range operands {
+			token.WalkExpression(op, func(leaf *token.Operand) {
+				if leaf.Type() != token.TypeLabel {
+					return
+				}
+				if _, isSubroutine := byName[leaf.Label]; isSubroutine && !used[leaf.Label] {
+					used[leaf.Label] = true
+					queue = append(queue, leaf.Label)
+				}
+				if value, isDefine := defines[leaf.Label]; isDefine && !followedDefines[leaf.Label] {
+					followedDefines[leaf.Label] = true
+					markReferences([]*token.Operand{value})
+				}
+			})
+		}
+	}
+
+	for _, tk := range program {
+		switch tk := tk.(type) {
+		case *token.Instruction:
+			markReferences(collectOperands(tk))
+		case *token.Data:
+			markReferences(tk.Values)
+		}
+	}
+
+	for len(queue) != 0 {
+		var name string
+		name, queue = queue[0], queue[1:]
+		for _, ins := range byName[name].Instructions {
+			markReferences(collectOperands(ins))
+		}
+	}
+
+	var o []*token.Subroutine
+	for _, sr := range subroutines {
+		if used[sr.Label] {
+			o = append(o, sr)
+		}
+	}
+	return o
+}
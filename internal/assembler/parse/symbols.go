@@ -0,0 +1,120 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/parse/symbols.go
+
+package parse
+
+import (
+	"fmt"
+	"github.com/codemicro/chip8/internal/assembler/token"
+)
+
+// programStart is the address that ROMs are loaded into memory at
+const programStart = 0x200
+
+// symbolTable holds the address of every label and the value of every @define
+type symbolTable struct {
+	addresses map[string]int
+	defines   map[string]*token.Operand
+	resolving map[string]bool // defines that are currently being evaluated, used to detect circular definitions
+}
+
+func newSymbolTable() *symbolTable {
+	return &symbolTable{
+		addresses: make(map[string]int),
+		defines:   make(map[string]*token.Operand),
+		resolving: make(map[string]bool),
+	}
+}
+
+func (s *symbolTable) exists(label string) bool {
+	_, isLabel := s.addresses[label]
+	_, isDefine := s.defines[label]
+	return isLabel || isDefine
+}
+
+// defineLabel adds a label to the symbol table, failing if it already exists
+func (s *symbolTable) defineLabel(label string, address int) error {
+	if s.exists(label) {
+		return fmt.Errorf("duplicate label %#v", label)
+	}
+	s.addresses[label] = address
+	return nil
+}
+
+// defineConstant adds a @define to the symbol table, failing if it already exists. The value is not evaluated until
+// it is used.
+func (s *symbolTable) defineConstant(label string, value *token.Operand) error {
+	if s.exists(label) {
+		return fmt.Errorf("duplicate label %#v", label)
+	}
+	s.defines[label] = value
+	return nil
+}
+
+// resolve returns the address of a label or the value of a define
+func (s *symbolTable) resolve(label string) (int, error) {
+	if address, found := s.addresses[label]; found {
+		return address, nil
+	}
+
+	value, found := s.defines[label]
+	if !found {
+		return 0, fmt.Errorf("undefined label %#v", label)
+	}
+
+	if s.resolving[label] {
+		return 0, fmt.Errorf("define %#v refers to itself", label)
+	}
+	s.resolving[label] = true
+	defer delete(s.resolving, label)
+
+	return evaluate(value, s)
+}
+
+// buildSymbolTable performs the first assembler pass, assigning an address to every labelled instruction and data
+// directive so that labels can be referenced before they are defined.
+func buildSymbolTable(tokens []token.Token) (*symbolTable, error) {
+	symbols := newSymbolTable()
+
+	for _, tk := range tokens {
+		if d, ok := tk.(*token.Define); ok {
+			if err := symbols.defineConstant(d.Label, d.Value); err != nil {
+				return nil, errorAt(d, err)
+			}
+		}
+	}
+
+	address := programStart
+
+	for _, tk := range tokens {
+		switch tk := tk.(type) {
+		case *token.Instruction:
+			if tk.Label != "" {
+				if err := symbols.defineLabel(tk.Label, address); err != nil {
+					return nil, errorAt(tk, err)
+				}
+			}
+			address += instructionSize(tk)
+		case *token.Data:
+			size, err := dataSize(tk, address, symbols)
+			if err != nil {
+				return nil, errorAt(tk, err)
+			}
+			if tk.Label != "" {
+				labelAddress := address
+				if tk.Directive == "align" {
+					// a label on an @align refers to the aligned address
+					labelAddress += size
+				}
+				if err := symbols.defineLabel(tk.Label, labelAddress); err != nil {
+					return nil, errorAt(tk, err)
+				}
+			}
+			address += size
+		}
+	}
+
+	return symbols, nil
+}
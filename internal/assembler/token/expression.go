@@ -0,0 +1,96 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/assembler/token/expression.go
+
+package token
+
+import "fmt"
+
+// Expression is a node in a constant expression. The leaves of an expression are always *Operand values of type
+// TypeValue or TypeLabel.
+type Expression interface {
+	fmt.Stringer
+	Pos() Position
+}
+
+// BinaryExpression is an expression of the form `Left Operator Right`
+type BinaryExpression struct {
+	Position
+	Operator string
+	Left     Expression
+	Right    Expression
+}
+
+func (b *BinaryExpression) String() string {
+	return fmt.Sprintf("(%s %s %s)", b.Left.String(), b.Operator, b.Right.String())
+}
+
+// UnaryExpression is an expression of the form `Operator Operand`
+type UnaryExpression struct {
+	Position
+	Operator string
+	Operand  Expression
+}
+
+func (u *UnaryExpression) String() string {
+	return fmt.Sprintf("%s%s", u.Operator, u.Operand.String())
+}
+
+// CallExpression is a call to one of the built-in functions, such as lo() or hi()
+type CallExpression struct {
+	Position
+	Function string
+	Argument Expression
+}
+
+func (c *CallExpression) String() string {
+	return fmt.Sprintf("%s(%s)", c.Function, c.Argument.String())
+}
+
+// WalkExpression calls fn for every leaf operand in e
+func WalkExpression(e Expression, fn func(op *Operand)) {
+	switch e := e.(type) {
+	case *Operand:
+		if e.Type() == TypeExpression {
+			WalkExpression(e.Expression, fn)
+		} else {
+			fn(e)
+		}
+	case *BinaryExpression:
+		WalkExpression(e.Left, fn)
+		WalkExpression(e.Right, fn)
+	case *UnaryExpression:
+		WalkExpression(e.Operand, fn)
+	case *CallExpression:
+		WalkExpression(e.Argument, fn)
+	}
+}
+
+// MapExpression returns a copy of e with every leaf operand replaced with the result of fn. fn may return its argument
+// unchanged.
+func MapExpression(e Expression, fn func(op *Operand) *Operand) Expression {
+	switch e := e.(type) {
+	case *Operand:
+		if e.Type() == TypeExpression {
+			x := *e
+			x.Expression = MapExpression(e.Expression, fn)
+			return &x
+		}
+		return fn(e)
+	case *BinaryExpression:
+		x := *e
+		x.Left = MapExpression(e.Left, fn)
+		x.Right = MapExpression(e.Right, fn)
+		return &x
+	case *UnaryExpression:
+		x := *e
+		x.Operand = MapExpression(e.Operand, fn)
+		return &x
+	case *CallExpression:
+		x := *e
+		x.Argument = MapExpression(e.Argument, fn)
+		return &x
+	}
+	return e
+}
@@ -18,19 +18,36 @@ const (
 	TypeMacro
 	TypeSubroutine
 	TypeInstruction
+	TypeData
 
 	TypeRegister
 	TypeValue
+	TypeLabel
+	TypeExpression
 )
 
 type Token interface {
 	fmt.Stringer
 	Type() Type
+	Pos() Position
 }
 
+// Position describes where in the source a token came from. Line and Column start from 1, and are zero if the
+// position is not known.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) Pos() Position { return p }
+
 type Operand struct {
+	Position
 	OperandType Type
 	Value       int
+	Label       string     // Label is only set if OperandType is TypeLabel
+	Expression  Expression // Expression is only set if OperandType is TypeExpression
 }
 
 func (o *Operand) Type() Type { return o.OperandType }
@@ -40,6 +57,12 @@ func (o *Operand) String() string {
 		return ""
 	}
 
+	if o.Type() == TypeLabel {
+		return o.Label
+	} else if o.Type() == TypeExpression {
+		return o.Expression.String()
+	}
+
 	var chr string
 	if o.Type() == TypeRegister {
 		chr = "$"
@@ -48,24 +71,47 @@ func (o *Operand) String() string {
 }
 
 type Instruction struct {
+	Position
 	Label  string
 	Opcode string
 	Arg1   *Operand // Arg1 may be nil
 	Arg2   *Operand // Arg2 may be nil
+	Arg3   *Operand // Arg3 may be nil
 }
 
 func (i *Instruction) Type() Type { return TypeInstruction }
 func (i *Instruction) String() string {
 	return fmt.Sprintf(
-		"%s %s %s %s",
+		"%s %s %s %s %s",
 		i.Label,
 		i.Opcode,
 		i.Arg1.String(),
 		i.Arg2.String(),
+		i.Arg3.String(),
 	)
 }
 
+// Data is a data directive (@byte, @word, @fill or @align) that emits bytes directly into the output
+type Data struct {
+	Position
+	Label     string
+	Directive string
+	Values    []*Operand
+}
+
+func (d *Data) Type() Type { return TypeData }
+func (d *Data) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s @%s", d.Label, d.Directive))
+	for _, v := range d.Values {
+		sb.WriteString(" ")
+		sb.WriteString(v.String())
+	}
+	return sb.String()
+}
+
 type Define struct {
+	Position
 	Label string
 	Value *Operand // Value may not be nil
 }
@@ -74,6 +120,7 @@ func (d *Define) Type() Type { return TypeDefine }
 func (d *Define) String() string { return fmt.Sprintf("define %s as %s", d.Label, d.Value.String()) }
 
 type Include struct {
+	Position
 	Filename string
 }
 
@@ -99,6 +146,7 @@ func (a *Argument) String() string {
 }
 
 type Macro struct {
+	Position
 	Instructions []*Instruction
 	Label        string
 	Arguments    []*Argument // Arguments may not have nil values
@@ -119,6 +167,7 @@ func (m *Macro) String() string {
 }
 
 type Subroutine struct {
+	Position
 	Label string
 	Instructions []*Instruction
 }
@@ -127,7 +176,7 @@ func (s *Subroutine) Type() Type { return TypeSubroutine }
 func (s *Subroutine) String() string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("macro %s\n", s.Label))
+	sb.WriteString(fmt.Sprintf("subroutine %s\n", s.Label))
 
 	for _, ins := range s.Instructions {
 		sb.WriteString("  ")
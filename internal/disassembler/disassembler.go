@@ -0,0 +1,222 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/disassembler/disassembler.go
+
+// Package disassembler turns ROMs back into source that can be assembled with c8asm.
+package disassembler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/codemicro/chip8/internal/instructions"
+)
+
+// programStart is the address that ROMs are loaded at
+const programStart = 0x200
+
+// dataBytesPerLine is the maximum number of values emitted in a single @byte declaration
+const dataBytesPerLine = 8
+
+// disassembly holds the state of a ROM being disassembled
+type disassembly struct {
+	rom []byte
+
+	// code maps the offset of the first byte of each reachable instruction to its opcode
+	code map[int]uint16
+	// occupied marks every byte that is part of a reachable instruction
+	occupied []bool
+
+	jumpTargets map[int]bool
+	callTargets map[int]bool
+}
+
+// Disassemble returns source code for rom in the syntax described by asmSyntax.txt. Instructions are found by
+// following every path of execution from the start of the program. Jump and call targets are given labels and any
+// bytes that are never executed are emitted as @byte data, meaning that assembling the output will always produce a
+// ROM that is byte-identical to the input.
+func Disassemble(rom []byte) []byte {
+	d := &disassembly{
+		rom:         rom,
+		code:        make(map[int]uint16),
+		occupied:    make([]bool, len(rom)),
+		jumpTargets: make(map[int]bool),
+		callTargets: make(map[int]bool),
+	}
+
+	d.trace(0)
+
+	return d.render()
+}
+
+// decode returns the opcode at offset and the size of the instruction if it is a known instruction that doesn't overlap
+// any other reachable instruction
+func (d *disassembly) decode(offset int) (uint16, int, bool) {
+	if offset < 0 || offset+1 >= len(d.rom) {
+		return 0, 0, false
+	}
+	opcode := binary.BigEndian.Uint16(d.rom[offset:])
+	def, found := instructions.Decode(opcode)
+	if !found {
+		return 0, 0, false
+	}
+	size := def.Size()
+	if offset+size > len(d.rom) {
+		return 0, 0, false
+	}
+	for i := 0; i < size; i += 1 {
+		if d.occupied[offset+i] {
+			return 0, 0, false
+		}
+	}
+	return opcode, size, true
+}
+
+// size returns the size of the instruction at offset, assuming it is two bytes long if it can't be decoded
+func (d *disassembly) size(offset int) int {
+	if offset+1 < len(d.rom) {
+		if def, found := instructions.Decode(binary.BigEndian.Uint16(d.rom[offset:])); found {
+			return def.Size()
+		}
+	}
+	return 2
+}
+
+// trace marks every instruction that can be reached from start
+func (d *disassembly) trace(start int) {
+	queue := []int{start}
+
+	for len(queue) != 0 {
+		var offset int
+		offset, queue = queue[0], queue[1:]
+
+		for {
+			if _, seen := d.code[offset]; seen {
+				break
+			}
+
+			opcode, size, ok := d.decode(offset)
+			if !ok {
+				break
+			}
+
+			d.code[offset] = opcode
+			for i := 0; i < size; i += 1 {
+				d.occupied[offset+i] = true
+			}
+
+			def, _ := instructions.Decode(opcode)
+			next := offset + size
+
+			switch def.Mnemonic {
+			case "jmp":
+				target := int(instructions.NNN(opcode)) - programStart
+				d.jumpTargets[target] = true
+				queue = append(queue, target)
+				next = -1
+			case "call":
+				target := int(instructions.NNN(opcode)) - programStart
+				d.callTargets[target] = true
+				queue = append(queue, target)
+			case "rtn", "jmpo":
+				// the destination isn't known until runtime
+				next = -1
+			case "exit":
+				next = -1
+			case "src", "srcx", "srr", "srrx", "skp", "skpx":
+				// skips jump over the whole of the next instruction, even if it's an `idxl`
+				queue = append(queue, next+d.size(next))
+			}
+
+			if next == -1 {
+				break
+			}
+			offset = next
+		}
+	}
+}
+
+// label returns the label for the instruction at offset, if it has one
+func (d *disassembly) label(offset int) string {
+	if _, isCode := d.code[offset]; !isCode {
+		return ""
+	}
+	if d.callTargets[offset] {
+		return fmt.Sprintf("sub%03x", offset+programStart)
+	}
+	if d.jumpTargets[offset] {
+		return fmt.Sprintf("loc%03x", offset+programStart)
+	}
+	return ""
+}
+
+// address formats an address operand, using a label if one exists
+func (d *disassembly) address(addr uint16) string {
+	if l := d.label(int(addr) - programStart); l != "" {
+		return l
+	}
+	return fmt.Sprintf("0x%03x", addr)
+}
+
+// instruction formats the instruction at offset
+func (d *disassembly) instruction(offset int) string {
+	opcode := d.code[offset]
+	def, _ := instructions.Decode(opcode)
+
+	x := instructions.X(opcode)
+	y := instructions.Y(opcode)
+
+	switch def.Format {
+	case instructions.FormatAddress:
+		return fmt.Sprintf("%s %s", def.Mnemonic, d.address(instructions.NNN(opcode)))
+	case instructions.FormatRegister:
+		return fmt.Sprintf("%s $%x", def.Mnemonic, x)
+	case instructions.FormatRegisterConst:
+		return fmt.Sprintf("%s $%x 0x%02x", def.Mnemonic, x, instructions.NN(opcode))
+	case instructions.FormatRegisterReg, instructions.FormatRegisterOptReg:
+		return fmt.Sprintf("%s $%x $%x", def.Mnemonic, x, y)
+	case instructions.FormatRegisterRegNib:
+		return fmt.Sprintf("%s $%x $%x %d", def.Mnemonic, x, y, instructions.N(opcode))
+	case instructions.FormatNibble:
+		return fmt.Sprintf("%s %d", def.Mnemonic, instructions.N(opcode))
+	case instructions.FormatPlanes:
+		return fmt.Sprintf("%s %d", def.Mnemonic, x)
+	case instructions.FormatLongAddress:
+		return fmt.Sprintf("%s 0x%04x", def.Mnemonic, binary.BigEndian.Uint16(d.rom[offset+2:]))
+	default:
+		return def.Mnemonic
+	}
+}
+
+func (d *disassembly) render() []byte {
+	b := new(bytes.Buffer)
+
+	for offset := 0; offset < len(d.rom); {
+		if _, isCode := d.code[offset]; isCode {
+			if l := d.label(offset); l != "" {
+				fmt.Fprintf(b, "%s:\n", l)
+			}
+			size := d.size(offset)
+			fmt.Fprintf(b, "    %-24s ; %03x: %x\n", d.instruction(offset), offset+programStart, d.rom[offset:offset+size])
+			offset += size
+			continue
+		}
+
+		// collect data bytes up to the next instruction
+		start := offset
+		var values []string
+		for offset < len(d.rom) && !d.occupied[offset] && len(values) < dataBytesPerLine {
+			values = append(values, fmt.Sprintf("0x%02x", d.rom[offset]))
+			offset += 1
+		}
+
+		line := "@byte"
+		for _, v := range values {
+			line += " " + v
+		}
+		fmt.Fprintf(b, "    %-24s ; %03x\n", line, start+programStart)
+	}
+
+	return b.Bytes()
+}
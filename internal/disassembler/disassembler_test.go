@@ -0,0 +1,55 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/disassembler/disassembler_test.go
+
+package disassembler
+
+import (
+	"bytes"
+	"github.com/codemicro/chip8/internal/assembler/lex"
+	"github.com/codemicro/chip8/internal/assembler/parse"
+	"strings"
+	"testing"
+)
+
+func Test_Disassemble(t *testing.T) {
+	rom := []byte{
+		0x00, 0xE0, // 200: clr
+		0xA2, 0x12, // 202: idx 0x212
+		0x22, 0x0C, // 204: call sub20c
+		0x3A, 0x01, // 206: src $a 0x01
+		0x12, 0x04, // 208: jmp loc204
+		0x12, 0x0A, // 20a: jmp loc20a
+		0x81, 0x26, // 20c: rsh $1 $2
+		0xD0, 0x15, // 20e: disp $0 $1 5
+		0x00, 0xEE, // 210: rtn
+		0x60, 0x90, // 212: sprite data that looks like an instruction
+		0x50, 0x13, // 214: unknown opcode
+		0xFF, // 216: trailing odd byte
+	}
+
+	src := Disassemble(rom)
+
+	for _, want := range []string{"sub20c:", "loc20a:", "call sub20c", "jmp loc20a", "rsh $1 $2", "disp $0 $1 5", "@byte 0x60 0x90 0x50 0x13 0xff"} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Errorf("output does not contain %q:\n%s", want, src)
+		}
+	}
+
+	if strings.Contains(string(src), "set $0") {
+		t.Errorf("unreachable bytes were disassembled as instructions:\n%s", src)
+	}
+
+	tokens, err := lex.Lex("", src)
+	if err != nil {
+		t.Fatalf("unable to lex output: %v\n%s", err, src)
+	}
+	reassembled, err := parse.Parse(tokens, nil)
+	if err != nil {
+		t.Fatalf("unable to assemble output: %v\n%s", err, src)
+	}
+	if !bytes.Equal(reassembled, rom) {
+		t.Fatalf("reassembled ROM differs (got %x, want %x)", reassembled, rom)
+	}
+}
@@ -0,0 +1,74 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/exchange/exchange.go
+
+// Package exchange passes displays and key presses between the goroutine running the VM and the goroutine running a
+// UI, without either having to wait for the other.
+package exchange
+
+import (
+	"github.com/codemicro/chip8/internal/emulator/vm"
+	"sync"
+)
+
+// Frames is a double buffer of displays. The VM goroutine publishes displays with Publish, and the UI goroutine picks
+// up the most recent one with Front. Displays published between two calls to Front are dropped.
+//
+// The zero value is an empty display, ready to use.
+type Frames struct {
+	mu sync.Mutex
+	// back is the most recently published display
+	back vm.Display
+	// fresh is true if back has been published since the last call to Front
+	fresh bool
+
+	// front is only used by the UI goroutine, so doesn't need to be guarded by mu
+	front vm.Display
+}
+
+// Publish makes d the next display to be returned by Front
+func (f *Frames) Publish(d vm.Display) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.back = d
+	f.fresh = true
+}
+
+// Front returns the most recently published display. The returned display must only be used by the goroutine that
+// calls Front, and only until the next call to Front.
+func (f *Frames) Front() *vm.Display {
+	f.mu.Lock()
+	if f.fresh {
+		f.front, f.back = f.back, f.front
+		f.fresh = false
+	}
+	f.mu.Unlock()
+	return &f.front
+}
+
+// Keys holds a snapshot of the keys that are pressed on the keypad. The UI goroutine takes a snapshot once per frame
+// with Set, and the VM goroutine reads it with Pressed.
+//
+// The zero value has no keys pressed, and is ready to use.
+type Keys struct {
+	mu      sync.Mutex
+	pressed []uint8
+}
+
+// Set replaces the snapshot with the keys in pressed
+func (k *Keys) Set(pressed []uint8) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.pressed = append(k.pressed[:0], pressed...)
+}
+
+// Pressed returns a copy of the most recent snapshot
+func (k *Keys) Pressed() []uint8 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.pressed) == 0 {
+		return nil
+	}
+	return append([]uint8(nil), k.pressed...)
+}
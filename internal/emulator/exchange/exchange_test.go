@@ -0,0 +1,161 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/exchange/exchange_test.go
+
+package exchange
+
+import (
+	"context"
+	"github.com/codemicro/chip8/internal/emulator/vm"
+	"testing"
+	"time"
+)
+
+// headless is a UI driver that exchanges frames and keys with a test acting as the UI goroutine
+type headless struct {
+	frames Frames
+	keys   Keys
+}
+
+func (h *headless) PublishNewDisplay(d vm.Display)                       { h.frames.Publish(d) }
+func (h *headless) GetPressedKeys() []uint8                              { return h.keys.Pressed() }
+func (h *headless) StartTone()                                           {}
+func (h *headless) StopTone()                                            {}
+func (h *headless) SetAudioPattern(pattern vm.AudioPattern, pitch uint8) {}
+func (h *headless) ClearAudioPattern()                                   {}
+
+func Test_Frames(t *testing.T) {
+	var f Frames
+
+	if *f.Front() != (vm.Display{}) {
+		t.Fatal("zero value Frames did not return an empty display")
+	}
+
+	// every pixel of each published display is the same, so a display that was torn while being exchanged would
+	// have a mixture of values
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i += 1 {
+			var d vm.Display
+			for y := range d.Pixels {
+				for x := range d.Pixels[y] {
+					d.Pixels[y][x] = uint8(i % 4)
+				}
+			}
+			f.Publish(d)
+		}
+	}()
+
+	check := func() {
+		d := f.Front()
+		first := d.Pixels[0][0]
+		for y := range d.Pixels {
+			for x := range d.Pixels[y] {
+				if d.Pixels[y][x] != first {
+					t.Fatalf("torn display at (%d, %d)", x, y)
+				}
+			}
+		}
+	}
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		check()
+	}
+
+	if f.Front().Pixels[0][0] != 499%4 {
+		t.Fatal("Front did not return the most recently published display")
+	}
+}
+
+func Test_Keys(t *testing.T) {
+	var k Keys
+
+	if k.Pressed() != nil {
+		t.Fatal("zero value Keys has keys pressed")
+	}
+
+	pressed := []uint8{0x01, 0x0A}
+	k.Set(pressed)
+	pressed[0] = 0x0F
+
+	got := k.Pressed()
+	if len(got) != 2 || got[0] != 0x01 || got[1] != 0x0A {
+		t.Fatalf("incorrect keys pressed (got %v, want [1 10])", got)
+	}
+
+	got[0] = 0x0F
+	if k.Pressed()[0] != 0x01 {
+		t.Fatal("modifying the result of Pressed changed the snapshot")
+	}
+}
+
+// Test_Headless runs a program in the VM's own goroutine while the test acts as the UI, to be run with -race
+func Test_Headless(t *testing.T) {
+	rom := []byte{
+		0x00, 0xE0, // clr
+		0xF0, 0x0A, // inp $0
+		0xF0, 0x29, // char $0
+		0x61, 0x00, // set $1 0
+		0xD1, 0x15, // disp $1 $1 5
+		0x00, 0xFD, // exit
+	}
+
+	h := &headless{}
+	c := vm.NewChip8(rom, h, 2000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- c.Run(ctx)
+	}()
+
+	// act as the UI's game loop, which snapshots the keys and draws the latest frame every update, while also
+	// using the VM's controls
+	var err error
+	for frame := 0; ; frame += 1 {
+		if frame == 10 {
+			h.keys.Set([]uint8{0x0A})
+		}
+		switch frame % 7 {
+		case 3:
+			c.Pause()
+		case 4:
+			c.Resume()
+		case 5:
+			c.SetClockSpeed(1000 + frame)
+		}
+		h.frames.Front()
+
+		select {
+		case err = <-result:
+		case <-time.After(time.Millisecond):
+			continue
+		}
+		break
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the font's "A" sprite
+	want := []byte{0xF0, 0x90, 0xF0, 0x90, 0x90}
+	d := h.frames.Front()
+	for y, row := range want {
+		for x := 0; x < 8; x += 1 {
+			set := row&(0x80>>x) != 0
+			if (d.Pixels[y][x] != 0) != set {
+				t.Fatalf("incorrect pixel at (%d, %d) in final display", x, y)
+			}
+		}
+	}
+}
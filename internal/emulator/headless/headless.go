@@ -0,0 +1,34 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/headless/headless.go
+
+// Package headless runs the VM without a window or sound, and saves displays as images so that they can be compared
+// against known good copies.
+package headless
+
+import "github.com/codemicro/chip8/internal/emulator/vm"
+
+// UI is a UI driver that does no graphics or audio. It keeps the most recently published display, and reports that
+// no keys are pressed.
+//
+// UI isn't safe for concurrent use, so the VM should be driven with RunFrame rather than Run.
+type UI struct {
+	Display vm.Display
+}
+
+func (u *UI) PublishNewDisplay(d vm.Display) {
+	u.Display = d
+}
+
+func (*UI) GetPressedKeys() []uint8 {
+	return nil
+}
+
+func (*UI) StartTone() {}
+
+func (*UI) StopTone() {}
+
+func (*UI) SetAudioPattern(pattern vm.AudioPattern, pitch uint8) {}
+
+func (*UI) ClearAudioPattern() {}
@@ -0,0 +1,234 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/headless/image.go
+
+package headless
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/codemicro/chip8/internal/emulator/vm"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Format is a file format that displays can be saved as
+type Format int
+
+const (
+	// FormatPNG is a PNG image with one pixel per display pixel, coloured with Palette
+	FormatPNG Format = iota
+	// FormatPBM is a plain (P1) portable bitmap, where pixels set in any drawing plane are black. The drawing planes
+	// that pixels are set in are lost.
+	FormatPBM
+	// FormatASCII is one line of text per row of the display, using the characters in asciiPixels
+	FormatASCII
+)
+
+// Palette is the colour of each combination of drawing planes in PNG images, in the same order as c8run's default
+// colours: background, first plane, second plane and both planes
+var Palette = color.Palette{
+	color.RGBA{R: 0xF9, G: 0xFF, B: 0xB3, A: 0xFF},
+	color.RGBA{R: 0x3D, G: 0x80, B: 0x26, A: 0xFF},
+	color.RGBA{R: 0xC2, G: 0x57, B: 0x1A, A: 0xFF},
+	color.RGBA{R: 0x1B, G: 0x3A, B: 0x4B, A: 0xFF},
+}
+
+// asciiPixels is the character used for each combination of drawing planes in ASCII art
+const asciiPixels = ".#o@"
+
+var ErrUnknownFormat = errors.New("unknown image format (expecting .png, .pbm or .txt)")
+
+// FormatFromFilename returns the format for a filename based on its extension, which should be .png, .pbm or .txt
+func FormatFromFilename(name string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png":
+		return FormatPNG, nil
+	case ".pbm":
+		return FormatPBM, nil
+	case ".txt":
+		return FormatASCII, nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+}
+
+// Image returns d as an image at its current resolution, with one pixel per display pixel. The colour index of each
+// pixel is its drawing plane mask, and the colours are taken from Palette.
+func Image(d *vm.Display) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, d.Width(), d.Height()), Palette)
+	for y := 0; y < d.Height(); y += 1 {
+		for x := 0; x < d.Width(); x += 1 {
+			img.SetColorIndex(x, y, d.Pixels[y][x])
+		}
+	}
+	return img
+}
+
+// Encode writes d to w in format f
+func Encode(w io.Writer, d *vm.Display, f Format) error {
+	img := Image(d)
+	bounds := img.Bounds()
+
+	switch f {
+	case FormatPNG:
+		return png.Encode(w, img)
+
+	case FormatPBM:
+		bw := bufio.NewWriter(w)
+		fmt.Fprintf(bw, "P1\n%d %d\n", bounds.Dx(), bounds.Dy())
+		for y := 0; y < bounds.Dy(); y += 1 {
+			for x := 0; x < bounds.Dx(); x += 1 {
+				if x != 0 {
+					bw.WriteByte(' ')
+				}
+				if img.ColorIndexAt(x, y) != 0 {
+					bw.WriteByte('1')
+				} else {
+					bw.WriteByte('0')
+				}
+			}
+			bw.WriteByte('\n')
+		}
+		return bw.Flush()
+
+	case FormatASCII:
+		bw := bufio.NewWriter(w)
+		for y := 0; y < bounds.Dy(); y += 1 {
+			for x := 0; x < bounds.Dx(); x += 1 {
+				bw.WriteByte(asciiPixels[img.ColorIndexAt(x, y)])
+			}
+			bw.WriteByte('\n')
+		}
+		return bw.Flush()
+	}
+
+	return ErrUnknownFormat
+}
+
+// decode reads an image in format f
+func decode(r io.Reader, f Format) (image.Image, error) {
+	switch f {
+	case FormatPNG:
+		return png.Decode(r)
+
+	case FormatPBM:
+		return decodePBM(r)
+
+	case FormatASCII:
+		var rows []string
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			if line := strings.TrimRight(sc.Text(), "\r"); line != "" {
+				rows = append(rows, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, errors.New("empty ASCII art")
+		}
+
+		img := image.NewPaletted(image.Rect(0, 0, len(rows[0]), len(rows)), Palette)
+		for y, row := range rows {
+			if len(row) != len(rows[0]) {
+				return nil, fmt.Errorf("ASCII art line %d has incorrect length (got %d, want %d)", y+1, len(row), len(rows[0]))
+			}
+			for x, ch := range []byte(row) {
+				i := strings.IndexByte(asciiPixels, ch)
+				if i == -1 {
+					return nil, fmt.Errorf("unexpected character %q in ASCII art at line %d", ch, y+1)
+				}
+				img.SetColorIndex(x, y, uint8(i))
+			}
+		}
+		return img, nil
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+// decodePBM reads a plain (P1) portable bitmap
+func decodePBM(r io.Reader) (image.Image, error) {
+	// remove comments, then split into tokens
+	var tokens []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		tokens = append(tokens, strings.Fields(line)...)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(tokens) < 3 || tokens[0] != "P1" {
+		return nil, errors.New("not a plain PBM file")
+	}
+	var width, height int
+	if _, err := fmt.Sscan(tokens[1]+" "+tokens[2], &width, &height); err != nil {
+		return nil, fmt.Errorf("invalid PBM size: %v", err)
+	}
+
+	// pixels may be written without separating whitespace
+	bits := strings.Join(tokens[3:], "")
+	if len(bits) != width*height {
+		return nil, fmt.Errorf("PBM has incorrect number of pixels (got %d, want %d)", len(bits), width*height)
+	}
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i, b := range []byte(bits) {
+		switch b {
+		case '0':
+			img.Pix[i] = 0xFF
+		case '1':
+			img.Pix[i] = 0x00
+		default:
+			return nil, fmt.Errorf("unexpected character %q in PBM", b)
+		}
+	}
+	return img, nil
+}
+
+// Compare reports whether d matches the image read from golden, which is in format f. The comparison is made after
+// converting d to f, so for formats that don't keep every detail of the display, such as PBM, the details that are lost
+// are ignored.
+func Compare(d *vm.Display, golden io.Reader, f Format) (bool, error) {
+	want, err := decode(golden, f)
+	if err != nil {
+		return false, fmt.Errorf("reading golden file: %w", err)
+	}
+
+	encoded := new(bytes.Buffer)
+	if err := Encode(encoded, d, f); err != nil {
+		return false, err
+	}
+	got, err := decode(encoded, f)
+	if err != nil {
+		return false, err
+	}
+
+	if got.Bounds() != want.Bounds() {
+		return false, nil
+	}
+	bounds := got.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 1 {
+		for x := bounds.Min.X; x < bounds.Max.X; x += 1 {
+			r1, g1, b1, a1 := got.At(x, y).RGBA()
+			r2, g2, b2, a2 := want.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,110 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/headless/image_test.go
+
+package headless
+
+import (
+	"bytes"
+	"errors"
+	"github.com/codemicro/chip8/internal/emulator/vm"
+	"strings"
+	"testing"
+)
+
+func testDisplay() *vm.Display {
+	d := &vm.Display{}
+	d.Pixels[0][0] = 1
+	d.Pixels[1][2] = 2
+	d.Pixels[31][63] = 3
+	return d
+}
+
+func Test_EncodeCompare(t *testing.T) {
+	for _, f := range []Format{FormatPNG, FormatPBM, FormatASCII} {
+		golden := new(bytes.Buffer)
+		if err := Encode(golden, testDisplay(), f); err != nil {
+			t.Fatal(err)
+		}
+
+		match, err := Compare(testDisplay(), bytes.NewReader(golden.Bytes()), f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !match {
+			t.Errorf("format %d: display does not match its own encoding", f)
+		}
+
+		different := testDisplay()
+		different.Pixels[10][10] = 1
+		match, err = Compare(different, bytes.NewReader(golden.Bytes()), f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match {
+			t.Errorf("format %d: different displays compared equal", f)
+		}
+
+		highRes := testDisplay()
+		highRes.HighRes = true
+		match, err = Compare(highRes, bytes.NewReader(golden.Bytes()), f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match {
+			t.Errorf("format %d: displays with different resolutions compared equal", f)
+		}
+	}
+}
+
+func Test_EncodeASCII(t *testing.T) {
+	b := new(bytes.Buffer)
+	if err := Encode(b, testDisplay(), FormatASCII); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) != vm.LowResHeight+1 || lines[vm.LowResHeight] != "" {
+		t.Fatalf("incorrect number of lines (got %d, want %d)", len(lines)-1, vm.LowResHeight)
+	}
+	if !strings.HasPrefix(lines[0], "#.") || !strings.HasPrefix(lines[1], "..o") || !strings.HasSuffix(lines[31], ".@") {
+		t.Fatalf("incorrect ASCII art:\n%s", b.String())
+	}
+}
+
+func Test_ComparePBM(t *testing.T) {
+	// PBM ignores drawing planes, so a display with the same pixels set in a different plane matches
+	golden := "P1\n# a comment\n64 32\n" + strings.Repeat("0", 64*32)
+	golden = golden[:len(golden)-64*32] + "1" + golden[len(golden)-64*32+1:]
+
+	d := &vm.Display{}
+	d.Pixels[0][0] = 2
+	match, err := Compare(d, strings.NewReader(golden), FormatPBM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Fatal("display does not match hand written PBM")
+	}
+}
+
+func Test_FormatFromFilename(t *testing.T) {
+	for name, want := range map[string]Format{
+		"a.png":      FormatPNG,
+		"dir/b.PBM":  FormatPBM,
+		"golden.txt": FormatASCII,
+	} {
+		got, err := FormatFromFilename(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: incorrect format (got %d, want %d)", name, got, want)
+		}
+	}
+
+	if _, err := FormatFromFilename("a.gif"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("unknown extension returned %v, want %v", err, ErrUnknownFormat)
+	}
+}
@@ -0,0 +1,82 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/runner/runner.go
+
+// Package runner keeps a VM running for the lifetime of a program, waiting for it to be restarted whenever the ROM it's
+// running stops.
+package runner
+
+import (
+	"context"
+	"github.com/codemicro/chip8/internal/emulator/vm"
+	"sync"
+)
+
+// Runner runs a VM until its context is cancelled. When the program exits or stops because of an error, the runner
+// waits for Restart to be called before running it again.
+type Runner struct {
+	vm *vm.Chip8
+	// onStop is called from the goroutine calling Run each time the program stops
+	onStop func(err error)
+
+	mu sync.Mutex
+	// stopped is true while Run is waiting to be restarted
+	stopped bool
+	// restart is signalled by Restart while stopped is true. stopped is cleared at the same time, so there is never
+	// more than one signal waiting.
+	restart chan struct{}
+}
+
+// New returns a runner for c. onStop is called with the error that stopped the program, or nil if it exited.
+func New(c *vm.Chip8, onStop func(err error)) *Runner {
+	return &Runner{
+		vm:      c,
+		onStop:  onStop,
+		restart: make(chan struct{}, 1),
+	}
+}
+
+// Run runs the VM until ctx is cancelled. The error that stopped the program is returned if it hadn't been restarted
+// when ctx was cancelled.
+func (r *Runner) Run(ctx context.Context) error {
+	for {
+		err := r.vm.Run(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		r.mu.Lock()
+		r.stopped = true
+		r.mu.Unlock()
+
+		r.onStop(err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-r.restart:
+		}
+	}
+}
+
+// Stopped returns true if the program has stopped and Run is waiting to be restarted
+func (r *Runner) Stopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopped
+}
+
+// Restart makes Run start running the VM again if the program has stopped, which should be done after the VM has been
+// reset or its state restored. It returns false, and does nothing, if the program is still running.
+func (r *Runner) Restart() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.stopped {
+		return false
+	}
+	r.stopped = false
+	r.restart <- struct{}{}
+	return true
+}
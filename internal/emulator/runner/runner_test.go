@@ -0,0 +1,82 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/runner/runner_test.go
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"github.com/codemicro/chip8/internal/emulator/headless"
+	"github.com/codemicro/chip8/internal/emulator/vm"
+	"testing"
+	"time"
+)
+
+// faultingROM waits for ten frames, then executes an unknown opcode
+var faultingROM = []byte{
+	0x60, 0x0A, // set $0 10
+	0xF0, 0x15, // dset $0
+	0xF0, 0x07, // dget $0
+	0x30, 0x00, // src $0 0
+	0x12, 0x04, // jmp 0x204
+	0xFF, 0xFF,
+}
+
+func Test_Runner(t *testing.T) {
+	// the VM is only reset while the runner is stopped, so the UI is never used by two goroutines at once
+	c := vm.NewChip8(faultingROM, &headless.UI{}, 500)
+
+	stops := make(chan error, 4)
+	r := New(c, func(err error) {
+		stops <- err
+	})
+
+	waitForStop := func() error {
+		select {
+		case err := <-stops:
+			return err
+		case <-time.After(2 * time.Second):
+			t.Fatal("program did not stop")
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx)
+	}()
+
+	// a restart while the program is running mustn't be acted on once it stops
+	if r.Restart() {
+		t.Fatal("restarted while the program was running")
+	}
+
+	if err := waitForStop(); !errors.Is(err, vm.ErrUnknownOpcode) {
+		t.Fatalf("program stopped with %v, want %v", err, vm.ErrUnknownOpcode)
+	}
+	if !r.Stopped() {
+		t.Fatal("runner is not stopped after the program stopped")
+	}
+
+	select {
+	case err := <-stops:
+		t.Fatalf("program ran again after stopping with an error, and stopped with %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	c.Reset()
+	if !r.Restart() {
+		t.Fatal("failed to restart a stopped program")
+	}
+	if err := waitForStop(); !errors.Is(err, vm.ErrUnknownOpcode) {
+		t.Fatalf("restarted program stopped with %v, want %v", err, vm.ErrUnknownOpcode)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, vm.ErrUnknownOpcode) {
+		t.Fatalf("Run returned %v, want %v", err, vm.ErrUnknownOpcode)
+	}
+}
@@ -6,8 +6,10 @@
 package ui
 
 import (
+	"github.com/codemicro/chip8/internal/emulator/vm"
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"math"
+	"sync"
 )
 
 const (
@@ -20,6 +22,47 @@ type stream struct {
 	position  int64
 	remaining []byte
 	toneFrequency int
+
+	// mu guards pattern and patternRate, which are set from the VM's goroutine
+	mu sync.Mutex
+	// pattern is the XO-CHIP audio pattern to play instead of the tone, if one has been loaded
+	pattern      *vm.AudioPattern
+	patternRate  float64
+	patternPhase float64 // position in the pattern, in samples
+}
+
+// setPattern switches the stream to playing pattern at the rate set by pitch
+func (s *stream) setPattern(pattern vm.AudioPattern, pitch uint8) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pattern = &pattern
+	s.patternRate = vm.PlaybackRate(pitch)
+}
+
+// clearPattern switches the stream back to playing the tone
+func (s *stream) clearPattern() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pattern = nil
+	s.patternPhase = 0
+}
+
+// sample returns the value of the pth sample of the output
+func (s *stream) sample(p int64, length int64) int16 {
+	const max = 32767
+
+	if s.pattern == nil {
+		return int16(math.Sin(2*math.Pi*float64(p)/float64(length)) * max)
+	}
+
+	// patterns are square waves, so are played more quietly than the tone to sound roughly as loud
+	const patternMax = max / 4
+	bit := s.pattern.Bit(int(s.patternPhase))
+	s.patternPhase = math.Mod(s.patternPhase+s.patternRate/sampleRate, float64(len(s.pattern)*8))
+	if bit {
+		return patternMax
+	}
+	return -patternMax
 }
 
 func (s *stream) Read(buf []byte) (int, error) {
@@ -35,11 +78,13 @@ func (s *stream) Read(buf []byte) (int, error) {
 		buf = make([]byte, len(origBuf)+4-len(origBuf)%4)
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	var length = int64(sampleRate / s.toneFrequency)
 	p := s.position / 4
 	for i := 0; i < len(buf)/4; i++ {
-		const max = 32767
-		b := int16(math.Sin(2*math.Pi*float64(p)/float64(length)) * max)
+		b := s.sample(p, length)
 		buf[4*i] = byte(b)
 		buf[4*i+1] = byte(b >> 8)
 		buf[4*i+2] = byte(b)
@@ -7,44 +7,63 @@ package ui
 
 import (
 	"errors"
+	"github.com/codemicro/chip8/internal/emulator/exchange"
+	"github.com/codemicro/chip8/internal/emulator/vm"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"image/color"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type UI struct {
 	Debug bool
 
 	audioPlayer *audio.Player
+	audioStream *stream
 	toneFrequency int
 
-	fgColour color.Color
-	bgColour color.Color
+	// palette holds the colour for each combination of drawing planes - see vm.Display
+	palette [1 << vm.NumPlanes]color.Color
 
 	width, height int
 	scale         int
 	windowTitle   string
 
-	nextDisplay    *[32][64]bool
-	currentDisplay [32][64]bool
-}
+	// frames and keys are shared with the VM goroutine
+	frames exchange.Frames
+	keys   exchange.Keys
 
-func NewUI(width, height, scale int, windowTitle string, toneFrequency int, fgColour, bgColour string) (*UI, error) {
+	// hotkeys maps keys that control the emulator to the function to call when they're pressed
+	hotkeys map[ebiten.Key]func()
+	// heldHotkeys maps keys that control the emulator while they're held down to their actions
+	heldHotkeys map[ebiten.Key]heldHotkey
 
-	fg, err := hexStringToColor(fgColour)
-	if err != nil {
-		return nil, err
-	}
+	statusLock  sync.Mutex
+	status      string
+	statusShown bool
+}
 
-	bg, err := hexStringToColor(bgColour)
-	if err != nil {
-		return nil, err
+// NewUI creates a new UI. The window is width*scale by height*scale pixels in size, and both low and high resolution
+// displays are stretched to fill it.
+//
+// colours are hex colours for the background, pixels set in the first drawing plane, pixels set in the second drawing
+// plane and pixels set in both drawing planes, in that order.
+func NewUI(width, height, scale int, windowTitle string, toneFrequency int, colours [1 << vm.NumPlanes]string) (*UI, error) {
+
+	var palette [1 << vm.NumPlanes]color.Color
+	for i, hx := range colours {
+		c, err := hexStringToColor(hx)
+		if err != nil {
+			return nil, err
+		}
+		palette[i] = c
 	}
 
-	p, err := audio.NewPlayer(audioContext, &stream{toneFrequency: toneFrequency})
+	st := &stream{toneFrequency: toneFrequency}
+	p, err := audio.NewPlayer(audioContext, st)
 	if err != nil {
 		return nil, err
 	}
@@ -56,10 +75,10 @@ func NewUI(width, height, scale int, windowTitle string, toneFrequency int, fgCo
 		windowTitle: windowTitle,
 
 		audioPlayer: p,
+		audioStream: st,
 		toneFrequency: toneFrequency,
 
-		fgColour: fg,
-		bgColour: bg,
+		palette: palette,
 	}
 	return d, nil
 }
@@ -118,29 +137,100 @@ func hexStringToColor(hx string) (color.Color, error) {
 	}, nil
 }
 
-func (*UI) Update() error {
+func (d *UI) Update() error {
+	// input can only be read on the game loop, so take a snapshot for the VM
+	var pressed []uint8
+	for _, p := range inpututil.PressedKeys() {
+		if x, ok := inputTranslationTable[p]; ok {
+			pressed = append(pressed, x)
+		}
+	}
+	d.keys.Set(pressed)
+
+	for key, action := range d.hotkeys {
+		if inpututil.IsKeyJustPressed(key) {
+			action()
+		}
+	}
+	for key, hk := range d.heldHotkeys {
+		if ebiten.IsKeyPressed(key) {
+			hk.held()
+		} else if inpututil.IsKeyJustReleased(key) {
+			hk.released()
+		}
+	}
+
+	d.statusLock.Lock()
+	defer d.statusLock.Unlock()
+
+	if !d.statusShown {
+		title := d.windowTitle
+		if d.status != "" {
+			title += " - " + d.status
+		}
+		ebiten.SetWindowTitle(title)
+		d.statusShown = true
+	}
+
 	return nil
 }
 
-func (d *UI) Draw(screen *ebiten.Image) {
+// AddHotkey calls action on the UI goroutine whenever key is pressed. key should not be one of the keys used for the
+// CHIP-8 keypad. AddHotkey must be called before Start.
+func (d *UI) AddHotkey(key ebiten.Key, action func()) {
+	if d.hotkeys == nil {
+		d.hotkeys = make(map[ebiten.Key]func())
+	}
+	d.hotkeys[key] = action
+}
+
+// heldHotkey is a hotkey added with AddHeldHotkey
+type heldHotkey struct {
+	held     func()
+	released func()
+}
 
-	if d.nextDisplay != nil {
-		d.currentDisplay, d.nextDisplay = *d.nextDisplay, nil
+// AddHeldHotkey calls held on the UI goroutine once per update while key is held down, then released once it's let
+// go. key should not be one of the keys used for the CHIP-8 keypad. AddHeldHotkey must be called before Start.
+func (d *UI) AddHeldHotkey(key ebiten.Key, held, released func()) {
+	if d.heldHotkeys == nil {
+		d.heldHotkeys = make(map[ebiten.Key]heldHotkey)
 	}
+	d.heldHotkeys[key] = heldHotkey{held: held, released: released}
+}
+
+// SetStatus shows status after the window title, or just the window title if status is empty. It is safe to call
+// SetStatus from any goroutine.
+func (d *UI) SetStatus(status string) {
+	d.statusLock.Lock()
+	defer d.statusLock.Unlock()
+	d.status = status
+	d.statusShown = false
+}
+
+// SetError reports that the VM has stopped because of err. The last frame stays on screen and the error is shown in
+// the window title. It is safe to call SetError from any goroutine.
+func (d *UI) SetError(err error) {
+	d.SetStatus("stopped: " + err.Error())
+}
+
+func (d *UI) Draw(screen *ebiten.Image) {
+
+	display := d.frames.Front()
+
+	// the screen is always laid out at high resolution, so each pixel of a low resolution display is drawn as a
+	// pixelSize by pixelSize square
+	pixelSize := vm.HighResWidth / display.Width()
 
-	for y := 0; y < 32; y += 1 {
-		for x := 0; x < 64; x += 1 {
-			var c color.Color
-			if d.currentDisplay[y][x] {
-				if x % 2 == 0 && d.Debug {
-					c = color.RGBA{
-						R: 255,
-					}
-				} else {
-					c = d.fgColour
+	for y := 0; y < vm.HighResHeight; y += 1 {
+		for x := 0; x < vm.HighResWidth; x += 1 {
+			dx, dy := x/pixelSize, y/pixelSize
+			pixel := display.Pixels[dy][dx]
+			c := d.palette[pixel]
+			if pixel != 0 && dx % 2 == 0 && d.Debug {
+				c = color.RGBA{
+					R: 255,
 				}
-			} else {
-				c = d.bgColour
 			}
 			screen.Set(x, y, c)
 		}
@@ -148,7 +238,7 @@ func (d *UI) Draw(screen *ebiten.Image) {
 }
 
 func (d *UI) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return d.width, d.height
+	return vm.HighResWidth, vm.HighResHeight
 }
 
 func (d *UI) Start() error {
@@ -157,9 +247,8 @@ func (d *UI) Start() error {
 	return ebiten.RunGame(d)
 }
 
-func (d *UI) PublishNewDisplay(inp [32][64]bool) {
-	x := inp // copy
-	d.nextDisplay = &x
+func (d *UI) PublishNewDisplay(inp vm.Display) {
+	d.frames.Publish(inp)
 }
 
 var inputTranslationTable = map[ebiten.Key]uint8{
@@ -181,16 +270,9 @@ var inputTranslationTable = map[ebiten.Key]uint8{
 	ebiten.KeyV:      0x0F,
 }
 
+// GetPressedKeys returns the keypad keys that were pressed when the UI was last updated
 func (d *UI) GetPressedKeys() []uint8 {
-	var o []uint8
-
-	for _, p := range inpututil.PressedKeys() {
-		if x, ok := inputTranslationTable[p]; ok {
-			o = append(o, x)
-		}
-	}
-
-	return o
+	return d.keys.Pressed()
 }
 
 func (d *UI) StartTone() {
@@ -203,4 +285,12 @@ func (d *UI) StopTone() {
 	if d.audioPlayer.IsPlaying() {
 		d.audioPlayer.Pause()
 	}
-}
\ No newline at end of file
+}
+
+func (d *UI) SetAudioPattern(pattern vm.AudioPattern, pitch uint8) {
+	d.audioStream.setPattern(pattern, pitch)
+}
+
+func (d *UI) ClearAudioPattern() {
+	d.audioStream.clearPattern()
+}
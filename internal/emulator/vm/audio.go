@@ -0,0 +1,27 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/audio.go
+
+package vm
+
+import "math"
+
+// AudioPattern is an XO-CHIP audio sample buffer. Each bit is a single 1-bit sample, played starting with the most
+// significant bit of the first byte and looping back to the start after the last.
+type AudioPattern [16]byte
+
+// defaultPitch is the initial value of the pitch register, which plays patterns at 4000 samples per second
+const defaultPitch = 64
+
+// PlaybackRate returns the number of samples per second that an audio pattern should be played at for a given value of
+// the pitch register
+func PlaybackRate(pitch uint8) float64 {
+	return 4000 * math.Pow(2, (float64(pitch)-64)/48)
+}
+
+// Bit returns the value of the ith sample in the pattern, where i wraps around the length of the pattern
+func (a *AudioPattern) Bit(i int) bool {
+	i %= len(a) * 8
+	return a[i/8]&(0x80>>(i%8)) != 0
+}
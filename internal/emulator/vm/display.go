@@ -0,0 +1,72 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/display.go
+
+package vm
+
+const (
+	LowResWidth  = 64
+	LowResHeight = 32
+
+	HighResWidth  = 128
+	HighResHeight = 64
+
+	// NumPlanes is the number of XO-CHIP drawing planes
+	NumPlanes = 2
+	// allPlanes is a plane mask that selects every drawing plane
+	allPlanes uint8 = 1<<NumPlanes - 1
+)
+
+// Display is a single frame of output. Pixels is always large enough for high resolution mode - in low resolution mode,
+// only the top-left LowResWidth by LowResHeight pixels are used.
+//
+// Each pixel is a mask of the drawing planes that are set at that location, where bit 0 is the first plane and bit 1 is
+// the second, giving four possible colours. Programs that don't use XO-CHIP planes only ever draw to the first plane.
+type Display struct {
+	HighRes bool
+	Pixels  [HighResHeight][HighResWidth]uint8
+}
+
+// Width returns the width of the display in its current resolution
+func (d *Display) Width() int {
+	if d.HighRes {
+		return HighResWidth
+	}
+	return LowResWidth
+}
+
+// Height returns the height of the display in its current resolution
+func (d *Display) Height() int {
+	if d.HighRes {
+		return HighResHeight
+	}
+	return LowResHeight
+}
+
+// clear turns off every pixel in the selected planes without changing the resolution
+func (d *Display) clear(planes uint8) {
+	for y := range d.Pixels {
+		for x := range d.Pixels[y] {
+			d.Pixels[y][x] &^= planes
+		}
+	}
+}
+
+// scroll moves the contents of the selected planes by dx pixels right and dy pixels down. Pixels that are moved off the
+// edge of the display are discarded and those that are uncovered are turned off.
+func (d *Display) scroll(dx, dy int, planes uint8) {
+	width, height := d.Width(), d.Height()
+	old := d.Pixels
+	d.clear(planes)
+
+	for y := 0; y < height; y += 1 {
+		for x := 0; x < width; x += 1 {
+			sx, sy := x-dx, y-dy
+			if sx < 0 || sx >= width || sy < 0 || sy >= height {
+				continue
+			}
+			d.Pixels[y][x] |= old[sy][sx] & planes
+		}
+	}
+}
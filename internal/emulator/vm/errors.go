@@ -0,0 +1,37 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/errors.go
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reasons that execution can fail. An *ExecError will wrap one of these, so they can be checked for using errors.Is.
+var (
+	ErrUnknownOpcode     = errors.New("unknown opcode")
+	ErrStackUnderflow    = errors.New("stack underflow")
+	ErrStackOverflow     = errors.New("stack overflow")
+	ErrMemoryOutOfBounds = errors.New("memory access out of bounds")
+)
+
+// ExecError is returned when the VM is unable to execute an instruction
+type ExecError struct {
+	// PC is the address of the instruction that failed
+	PC uint16
+	// Opcode is the first two bytes of the instruction that failed
+	Opcode uint16
+	// Reason is why the instruction failed
+	Reason error
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("%s at 0x%04x (opcode %04x)", e.Reason, e.PC, e.Opcode)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Reason
+}
@@ -0,0 +1,74 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/errors_test.go
+
+package vm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func Test_UnknownOpcode(t *testing.T) {
+	c, _ := vmFixtureWithoutTick([]byte{0xFF, 0xFF})
+
+	err := c.tick()
+
+	var execErr *ExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("unknown opcode returned %v, want an *ExecError", err)
+	}
+	if execErr.PC != 0x200 || execErr.Opcode != 0xFFFF {
+		t.Fatalf("unknown opcode error has incorrect location (got pc %x opcode %x, want pc 200 opcode ffff)", execErr.PC, execErr.Opcode)
+	}
+	if !errors.Is(err, ErrUnknownOpcode) {
+		t.Fatalf("unknown opcode error has incorrect reason (got %v, want %v)", execErr.Reason, ErrUnknownOpcode)
+	}
+}
+
+func Test_StackUnderflow(t *testing.T) {
+	c, _ := vmFixtureWithoutTick([]byte{0x00, 0xEE})
+
+	err := c.tick()
+
+	var execErr *ExecError
+	if !errors.As(err, &execErr) || !errors.Is(err, ErrStackUnderflow) {
+		t.Fatalf("00EE with an empty stack returned %v, want a stack underflow *ExecError", err)
+	}
+	if execErr.PC != 0x200 {
+		t.Fatalf("stack underflow error has incorrect pc (got %x, want 200)", execErr.PC)
+	}
+}
+
+func Test_RunReturnsExecError(t *testing.T) {
+	// set v0 0x01, then an unknown opcode
+	c, _ := vmFixtureWithoutTick([]byte{0x60, 0x01, 0xFF, 0xFF})
+
+	err := c.Run(context.Background())
+
+	var execErr *ExecError
+	if !errors.As(err, &execErr) || execErr.PC != 0x202 {
+		t.Fatalf("Run returned %v, want an *ExecError at 0x202", err)
+	}
+	if c.v0 != 0x01 {
+		t.Fatal("instructions before the failing instruction were not executed")
+	}
+}
+
+func Test_StackOverflow(t *testing.T) {
+	// call 0x200 forever
+	c, _ := vmFixtureWithoutTick([]byte{0x22, 0x00})
+	c.MaxStackDepth = 4
+
+	for i := 0; i < c.MaxStackDepth; i += 1 {
+		if err := c.tick(); err != nil {
+			t.Fatalf("call %d returned %v", i, err)
+		}
+	}
+
+	if err := c.tick(); !errors.Is(err, ErrStackOverflow) {
+		t.Fatalf("call with a full stack returned %v, want %v", err, ErrStackOverflow)
+	}
+}
@@ -5,108 +5,193 @@
 
 package vm
 
-import (
-	"encoding/binary"
-	"math/rand"
-	"time"
-)
+// skipNextInstruction moves the program counter past the next instruction, which is four bytes long if it is
+// `F000 NNNN`
+func (c *Chip8) skipNextInstruction() error {
+	next, err := c.readWord(int(c.pc))
+	if err != nil {
+		return err
+	}
+	if next == 0xF000 {
+		c.pc += 4
+	} else {
+		c.pc += 2
+	}
+	return nil
+}
 
-var random = rand.New(rand.NewSource(time.Now().UnixNano()))
+// clearScreen - 00E0 clear the selected drawing planes
+func (c *Chip8) clearScreen() error {
+	c.disp.clear(c.planes)
+	c.ui.PublishNewDisplay(c.disp)
+	return nil
+}
+
+// scrollDown - 00CN scroll the selected drawing planes N pixels down
+func (c *Chip8) scrollDown() error {
+	c.disp.scroll(0, int(c.get4BitConstant()), c.planes)
+	c.ui.PublishNewDisplay(c.disp)
+	return nil
+}
+
+// scrollUp - 00DN scroll the selected drawing planes N pixels up
+func (c *Chip8) scrollUp() error {
+	c.disp.scroll(0, -int(c.get4BitConstant()), c.planes)
+	c.ui.PublishNewDisplay(c.disp)
+	return nil
+}
 
-// clearScreen - 00E0
-func (c *Chip8) clearScreen() {
-	c.disp = [32][64]bool{}
+// scrollRight - 00FB scroll the selected drawing planes 4 pixels right
+func (c *Chip8) scrollRight() error {
+	c.disp.scroll(4, 0, c.planes)
 	c.ui.PublishNewDisplay(c.disp)
+	return nil
+}
+
+// scrollLeft - 00FC scroll the selected drawing planes 4 pixels left
+func (c *Chip8) scrollLeft() error {
+	c.disp.scroll(-4, 0, c.planes)
+	c.ui.PublishNewDisplay(c.disp)
+	return nil
+}
+
+// exit - 00FD stop execution
+func (c *Chip8) exit() error {
+	c.exited = true
+	return nil
+}
+
+// lowRes - 00FE switch to 64x32 low resolution mode and clear every drawing plane
+func (c *Chip8) lowRes() error {
+	c.disp.HighRes = false
+	c.disp.clear(allPlanes)
+	c.ui.PublishNewDisplay(c.disp)
+	return nil
+}
+
+// highRes - 00FF switch to 128x64 high resolution mode and clear every drawing plane
+func (c *Chip8) highRes() error {
+	c.disp.HighRes = true
+	c.disp.clear(allPlanes)
+	c.ui.PublishNewDisplay(c.disp)
+	return nil
 }
 
 // subroutineReturn - 00EE
-func (c *Chip8) subroutineReturn() {
-	c.pc = c.stack.Pop()
+func (c *Chip8) subroutineReturn() error {
+	pc, err := c.stack.Pop()
+	if err != nil {
+		return err
+	}
+	c.pc = pc
+	return nil
 }
 
 // jump - 1NNN
-func (c *Chip8) jump() {
+func (c *Chip8) jump() error {
 	c.pc = c.getAddressFromCIR()
+	return nil
 }
 
 // subroutineCall - 2NNN
-func (c *Chip8) subroutineCall() {
+func (c *Chip8) subroutineCall() error {
 	nnn := c.getAddressFromCIR()
-	c.stack.Push(c.pc)
+	if err := c.stack.Push(c.pc, c.MaxStackDepth); err != nil {
+		return err
+	}
 	c.pc = nnn
+	return nil
 }
 
 // skipEqRegConst - 3XNN skip one if register equal to constant
-func (c *Chip8) skipEqRegConst() {
+func (c *Chip8) skipEqRegConst() error {
 	nn := c.get8bitConstant()
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	if nn == *vx {
-		c.pc += 2
+		return c.skipNextInstruction()
 	}
+	return nil
 }
 
 // skipNotEqRegConst - 4XNN skip one if register not equal to constant
-func (c *Chip8) skipNotEqRegConst() {
+func (c *Chip8) skipNotEqRegConst() error {
 	nn := c.get8bitConstant()
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	if nn != *vx {
-		c.pc += 2
+		return c.skipNextInstruction()
 	}
+	return nil
 }
 
 // skipEqRegReg - 5XY0 skip one if registers equal
-func (c *Chip8) skipEqRegReg() {
+func (c *Chip8) skipEqRegReg() error {
 	x := c.getRegisterPointer(c.cir[0] & 0x0F)
 	y := c.getRegisterPointer(c.cir[1] >> 4)
 	if *x == *y {
-		c.pc += 2
+		return c.skipNextInstruction()
 	}
+	return nil
 }
 
 // setRegisterToConstant - 6XNN set VX to NN
-func (c *Chip8) setRegisterToConstant() {
+func (c *Chip8) setRegisterToConstant() error {
 	nn := c.get8bitConstant()
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	*vx = nn
+	return nil
 }
 
 // addConstantToRegister - 7XNN add NN to VX without setting carry flag
-func (c *Chip8) addConstantToRegister() {
+func (c *Chip8) addConstantToRegister() error {
 	nn := c.get8bitConstant()
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	*vx += nn
+	return nil
 }
 
 // setRegisterToRegister - 8XY0 set VX to VY
-func (c *Chip8) setRegisterToRegister() {
+func (c *Chip8) setRegisterToRegister() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vy := c.getRegisterPointer(c.cir[1] >> 4)
 	*vx = *vy
+	return nil
 }
 
-// setRegisterToLogicalOr - 8XY1 set VX to logical OR of VX and VY
-func (c *Chip8) setRegisterToLogicalOr(){
+// setRegisterToLogicalOr - 8XY1 set VX to logical OR of VX and VY. VF is reset if ResetFlagOnLogic is true.
+func (c *Chip8) setRegisterToLogicalOr() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vy := c.getRegisterPointer(c.cir[1] >> 4)
 	*vx = *vx | *vy
+	if c.ResetFlagOnLogic {
+		c.vf = 0x00
+	}
+	return nil
 }
 
-// setRegisterToLogicalAnd - 8XY2 set VX to logical AND of VX and VY
-func (c *Chip8) setRegisterToLogicalAnd(){
+// setRegisterToLogicalAnd - 8XY2 set VX to logical AND of VX and VY. VF is reset if ResetFlagOnLogic is true.
+func (c *Chip8) setRegisterToLogicalAnd() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vy := c.getRegisterPointer(c.cir[1] >> 4)
 	*vx = *vx & *vy
+	if c.ResetFlagOnLogic {
+		c.vf = 0x00
+	}
+	return nil
 }
 
-// setRegisterToLogicalXor - 8XY3 set VX to logical XOR of VX and VY
-func (c *Chip8) setRegisterToLogicalXor(){
+// setRegisterToLogicalXor - 8XY3 set VX to logical XOR of VX and VY. VF is reset if ResetFlagOnLogic is true.
+func (c *Chip8) setRegisterToLogicalXor() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vy := c.getRegisterPointer(c.cir[1] >> 4)
 	*vx = *vx ^ *vy
+	if c.ResetFlagOnLogic {
+		c.vf = 0x00
+	}
+	return nil
 }
 
 // setRegisterToSum - 8XY4 set VX to the sum of VX and VY then set the carry flag as appropriate
-func (c *Chip8) setRegisterToSum() {
+func (c *Chip8) setRegisterToSum() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vy := c.getRegisterPointer(c.cir[1] >> 4)
 	vf := &c.vf
@@ -120,10 +205,11 @@ func (c *Chip8) setRegisterToSum() {
 	} else {
 		*vf = 0x00
 	}
+	return nil
 }
 
 // setRegisterToDifferenceA - 8XY5 set VX to VX - VY then set the carry flag as appropriate
-func (c *Chip8) setRegisterToDifferenceA() {
+func (c *Chip8) setRegisterToDifferenceA() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vy := c.getRegisterPointer(c.cir[1] >> 4)
 	vf := &c.vf
@@ -137,11 +223,12 @@ func (c *Chip8) setRegisterToDifferenceA() {
 	} else {
 		*vf = 0x00
 	}
+	return nil
 }
 
 // shiftRight - 8XY6 set VX to VY (if CopyRegistersOnShift), shift the value of VX 1 bit right and set VF to the bit
 // shifted out
-func (c *Chip8) shiftRight() {
+func (c *Chip8) shiftRight() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vf := &c.vf
 
@@ -154,11 +241,12 @@ func (c *Chip8) shiftRight() {
 
 	*vx = *vx >> 1
 	*vf = shiftedBit
+	return nil
 }
 
 // shiftLeft - 8XYE set VX to VY (if CopyRegistersOnShift), shift the value of VX 1 bit left and set VF to the bit
 // shifted out
-func (c *Chip8) shiftLeft() {
+func (c *Chip8) shiftLeft() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vf := &c.vf
 
@@ -171,10 +259,11 @@ func (c *Chip8) shiftLeft() {
 
 	*vx = *vx << 1
 	*vf = shiftedBit
+	return nil
 }
 
 // setRegisterToDifferenceB - 8XY7 set VX to VY - VX then set the carry flag as appropriate
-func (c *Chip8) setRegisterToDifferenceB() {
+func (c *Chip8) setRegisterToDifferenceB() error {
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	vy := c.getRegisterPointer(c.cir[1] >> 4)
 	vf := &c.vf
@@ -188,24 +277,38 @@ func (c *Chip8) setRegisterToDifferenceB() {
 	} else {
 		*vf = 0x00
 	}
+	return nil
 }
 
 // skipNotEqRegReg - 9XY0 skip one if registers not equal
-func (c *Chip8) skipNotEqRegReg() {
+func (c *Chip8) skipNotEqRegReg() error {
 	regX := c.getRegisterPointer(c.cir[0] & 0x0F)
 	regY := c.getRegisterPointer(c.cir[1] >> 4)
 	if *regX != *regY {
-		c.pc += 2
+		return c.skipNextInstruction()
 	}
+	return nil
 }
 
 // setIndexRegister - ANNN set index register to NNN
-func (c *Chip8) setIndexRegister() {
+func (c *Chip8) setIndexRegister() error {
 	c.ir = c.getAddressFromCIR()
+	return nil
+}
+
+// setIndexRegisterLong - F000 NNNN set index register to the 16-bit address NNNN that follows the instruction
+func (c *Chip8) setIndexRegisterLong() error {
+	nnnn, err := c.readWord(int(c.pc))
+	if err != nil {
+		return err
+	}
+	c.ir = nnnn
+	c.pc += 2
+	return nil
 }
 
 // jumpWithOffset - BNNN set PC to NNN + V0 - if VariableOffsetRegister, BXNN set PC to XNN + VX
-func (c *Chip8) jumpWithOffset() {
+func (c *Chip8) jumpWithOffset() error {
 	nnn := c.getAddressFromCIR()
 	offset := c.v0
 
@@ -214,118 +317,158 @@ func (c *Chip8) jumpWithOffset() {
 	}
 
 	c.pc = nnn + uint16(offset)
+	return nil
 }
 
 // random - CXNN generate a random byte, AND it with NN and store in VX
-func (c *Chip8) random() {
-	rnd := make([]byte, 4)
-	binary.BigEndian.PutUint32(rnd, random.Uint32())
+func (c *Chip8) random() error {
+	rnd := byte(c.rng.Uint64() >> 56)
 
 	vx := c.getRegisterPointer(c.cir[0] & 0x0F)
 	nn := c.get8bitConstant()
 
-	*vx = rnd[0] & nn
+	*vx = rnd & nn
+	return nil
 }
 
 // display - DXYN draw an N pixel tall sprite from the memory location in the index register at the coordinate of the
-// values in (VX, VY)
-func (c *Chip8) display() {
-	spriteHeight := c.get4BitConstant()
+// values in (VX, VY). If N is zero, a 16x16 sprite is drawn instead, made up of 16 two-byte rows. Pixels that go off the
+// edge of the display are clipped if ClipSprites is true, else they wrap.
+//
+// The sprite is drawn to each of the selected drawing planes in turn. If more than one plane is selected, the data for
+// each plane follows on directly from the data for the previous one.
+func (c *Chip8) display() error {
+	width, height := c.disp.Width(), c.disp.Height()
+
+	spriteHeight := int(c.get4BitConstant())
+	spriteWidth := 8
+	if spriteHeight == 0 {
+		spriteHeight = 16
+		spriteWidth = 16
+	}
+	bytesPerRow := spriteWidth / 8
 
-	startingXCoord := int(*c.getRegisterPointer(c.cir[0] & 0x0F) % 64)
-	startingYCoord := int(*c.getRegisterPointer(c.cir[1] >> 4) % 32)
+	startingXCoord := int(*c.getRegisterPointer(c.cir[0] & 0x0F)) % width
+	startingYCoord := int(*c.getRegisterPointer(c.cir[1] >> 4)) % height
 
 	vf := c.getRegisterPointer(0x0F)
 	*vf = 0x00
 
-	for y := 0; y < int(spriteHeight); y += 1 {
+	addr := int(c.ir)
 
-		if startingYCoord+y >= 32 { // if we'll be trying to draw out of bounds
+	for plane := uint8(0); plane < NumPlanes; plane += 1 {
+		planeMask := uint8(1) << plane
+		if c.planes&planeMask == 0 {
 			continue
 		}
 
-		rowData := c.memory[int(c.ir)+y]
-		for x := 0; x < 8; x += 1 {
+		for y := 0; y < spriteHeight; y += 1 {
 
-			if startingXCoord+x >= 64 {
-				continue
+			var rowData uint16
+			for i := 0; i < bytesPerRow; i += 1 {
+				b, err := c.readMemory(addr)
+				if err != nil {
+					return err
+				}
+				rowData = rowData<<8 | uint16(b)
+				addr += 1
 			}
+			rowData <<= 16 - spriteWidth // align the first pixel with the most significant bit
 
-			pixelData := rowData & 0x80 // get most significant bit - ie, 10000000
-
-			if pixelData == 0x80 { // if most significant bit is set
-				currentValue := c.disp[startingYCoord+y][startingXCoord+x]
-				c.disp[startingYCoord+y][startingXCoord+x] = !currentValue
-				if currentValue {
-					*vf = 0x01
+			yCoord := startingYCoord + y
+			if yCoord >= height { // if we'll be trying to draw out of bounds
+				if c.ClipSprites {
+					continue
 				}
+				yCoord %= height
 			}
 
-			rowData = rowData << 1
+			for x := 0; x < spriteWidth; x += 1 {
+
+				xCoord := startingXCoord + x
+				if xCoord >= width {
+					if c.ClipSprites {
+						continue
+					}
+					xCoord %= width
+				}
+
+				pixelData := rowData & 0x8000 // get most significant bit - ie, 10000000 00000000
+
+				if pixelData == 0x8000 { // if most significant bit is set
+					currentValue := c.disp.Pixels[yCoord][xCoord]
+					c.disp.Pixels[yCoord][xCoord] ^= planeMask
+					if currentValue&planeMask != 0 {
+						*vf = 0x01
+					}
+				}
+
+				rowData = rowData << 1
+			}
 		}
 	}
 
 	c.ui.PublishNewDisplay(c.disp)
+
+	if c.WaitForVBlank {
+		c.waitingForVBlank = true
+	}
+	return nil
 }
 
 // skipIfKey - EX9E skip one if key with the value stored in VX is pressed
-func (c *Chip8) skipIfKey() {
-	vxn := *c.getRegisterPointer(c.cir[0] & 0x0F)
-	pressedKeys := c.ui.GetPressedKeys()
-
-	for _, key := range pressedKeys {
-		if key == vxn {
-			c.pc += 2
-			break
-		}
+func (c *Chip8) skipIfKey() error {
+	if c.keyPressed(*c.getRegisterPointer(c.cir[0] & 0x0F)) {
+		return c.skipNextInstruction()
 	}
+	return nil
 }
 
 // skipIfNotKey - EXA1 skip one if key with the value stored in VX is not pressed
-func (c *Chip8) skipIfNotKey() {
-	vxn := *c.getRegisterPointer(c.cir[0] & 0x0F)
-	pressedKeys := c.ui.GetPressedKeys()
-
-	for _, key := range pressedKeys {
-		if key == vxn {
-			return
-		}
+func (c *Chip8) skipIfNotKey() error {
+	if c.keyPressed(*c.getRegisterPointer(c.cir[0] & 0x0F)) {
+		return nil
 	}
-	c.pc += 2
+	return c.skipNextInstruction()
 }
 
 // getDelayTimer - FX07 set value of VX to the current value of the delay timer
-func (c *Chip8) getDelayTimer() {
+func (c *Chip8) getDelayTimer() error {
 	*c.getRegisterPointer(c.cir[0] & 0x0F) = c.delay
+	return nil
 }
 
 // setDelayTimer - FX15 set delay timer to value of VX
-func (c *Chip8) setDelayTimer() {
+func (c *Chip8) setDelayTimer() error {
 	c.delay = *c.getRegisterPointer(c.cir[0] & 0x0F)
+	return nil
 }
 
 // setSoundTimer - FX18 set sound timer to the value of VX
-func (c *Chip8) setSoundTimer() {
+func (c *Chip8) setSoundTimer() error {
 	c.sound = *c.getRegisterPointer(c.cir[0] & 0x0F)
+	return nil
 }
 
 // getPressedKey - FX0A blocks until a key is pressed. Stores that key's value in VX then continues.
-func (c *Chip8) getPressedKey() {
+func (c *Chip8) getPressedKey() error {
 	// TODO: On the original COSMAC VIP, the key was only registered when it was pressed and then released.
 
-	pressedKeys := c.ui.GetPressedKeys()
-	if len(pressedKeys) == 0 {
-		// block
-		c.pc -= 2
-		return
+	for key := byte(0); key < 16; key += 1 {
+		if c.keyPressed(key) {
+			*c.getRegisterPointer(c.cir[0] & 0x0F) = key
+			return nil
+		}
 	}
 
-	*c.getRegisterPointer(c.cir[0] & 0x0F) = pressedKeys[0]
+	// block
+	c.pc -= 2
+	return nil
 }
 
 // addToIndexRegister - FX1E adds the value of VX to the index register and set VF accordingly if the index register
 // "overflows" above 0x0FFF. Setting VF does not occur if DisableSetFlagOnIrOverflow is true.
-func (c *Chip8) addToIndexRegister() {
+func (c *Chip8) addToIndexRegister() error {
 	c.ir += uint16(*c.getRegisterPointer(c.cir[0] & 0x0F))
 
 	if !c.DisableSetFlagOnIrOverflow {
@@ -335,50 +478,166 @@ func (c *Chip8) addToIndexRegister() {
 			c.vf = 0x00
 		}
 	}
+	return nil
 }
 
 // getFontCharacter - FX29 set the index register to the address of the hex character in VX
-func (c *Chip8) getFontCharacter() {
+func (c *Chip8) getFontCharacter() error {
 	c.ir = getFontCharacterLocation(*c.getRegisterPointer(c.cir[0] & 0x0F))
+	return nil
+}
+
+// getBigFontCharacter - FX30 set the index register to the address of the large hex character in VX
+func (c *Chip8) getBigFontCharacter() error {
+	c.ir = getBigFontCharacterLocation(*c.getRegisterPointer(c.cir[0] & 0x0F))
+	return nil
 }
 
 // convertToDecimal - FX33 take the value of VX, converts it to a denary number and the put each individual digit in the
 // memory location specified by the index register + the digit number.
 // Eg 0x9C -> 156 -> memory[ic] = 1, memory[ic+1] = 5, memory[ic+2] = 6
-func (c *Chip8) convertToDecimal() {
+func (c *Chip8) convertToDecimal() error {
 	vxn := *c.getRegisterPointer(c.cir[0] & 0x0F)
 
 	x := vxn % 10
 	y := ((vxn - x) / 10) % 10
 	z := (vxn - x - y*10) / 100
 
-	c.memory[c.ir] = z
-	c.memory[c.ir+1] = y
-	c.memory[c.ir+2] = x
+	for i, digit := range []byte{z, y, x} {
+		if err := c.writeMemory(int(c.ir)+i, digit); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // storeMemory - FX55 store the value of each general purpose register from V0 to VX inclusive in consecutive memory
 // addresses starting from the current value of the index register. If IncrementIndexRegisterOnLoadSave is true, the
-// index register will be incremented as a result of this process. Else, a temporary variable will be used.
-func (c *Chip8) storeMemory() {
+// index register will be left pointing at the address after the last register. Else, a temporary variable will be
+// used.
+func (c *Chip8) storeMemory() error {
 	x := c.cir[0] & 0x0F
 	for i := byte(0x00); i <= x; i += 1 {
-		c.memory[c.ir + uint16(i)] = *c.getRegisterPointer(i)
+		if err := c.writeMemory(int(c.ir)+int(i), *c.getRegisterPointer(i)); err != nil {
+			return err
+		}
 	}
 	if c.IncrementIndexRegisterOnLoadSave {
-		c.ir += uint16(x)
+		c.ir += uint16(x) + 1
 	}
+	return nil
 }
 
 // loadMemory - FX65 loads the value of each general purpose register from V0 to VX inclusive from consecutive memory
 // addresses starting from the current value of the index register. If IncrementIndexRegisterOnLoadSave is true, the
-// index register will be incremented as a result of this process. Else, a temporary variable will be used.
-func (c *Chip8) loadMemory() {
+// index register will be left pointing at the address after the last register. Else, a temporary variable will be
+// used.
+func (c *Chip8) loadMemory() error {
 	x := c.cir[0] & 0x0F
 	for i := byte(0x00); i <= x; i += 1 {
-		*c.getRegisterPointer(i) = c.memory[c.ir + uint16(i)]
+		b, err := c.readMemory(int(c.ir) + int(i))
+		if err != nil {
+			return err
+		}
+		*c.getRegisterPointer(i) = b
 	}
 	if c.IncrementIndexRegisterOnLoadSave {
-		c.ir += uint16(x)
+		c.ir += uint16(x) + 1
 	}
-}
\ No newline at end of file
+	return nil
+}
+
+// storeFlags - FX75 store the value of each general purpose register from V0 to VX inclusive in the RPL flags
+func (c *Chip8) storeFlags() error {
+	x := c.cir[0] & 0x0F
+	for i := byte(0x00); i <= x; i += 1 {
+		c.rpl[i] = *c.getRegisterPointer(i)
+	}
+	return nil
+}
+
+// loadFlags - FX85 loads the value of each general purpose register from V0 to VX inclusive from the RPL flags
+func (c *Chip8) loadFlags() error {
+	x := c.cir[0] & 0x0F
+	for i := byte(0x00); i <= x; i += 1 {
+		*c.getRegisterPointer(i) = c.rpl[i]
+	}
+	return nil
+}
+
+// storeMemoryRange - 5XY2 store the value of each general purpose register from VX to VY inclusive in consecutive
+// memory addresses starting from the current value of the index register. If X is greater than Y, the registers are
+// stored in reverse order. The index register is not changed.
+func (c *Chip8) storeMemoryRange() error {
+	x := c.cir[0] & 0x0F
+	y := c.cir[1] >> 4
+	for i, reg := range registerRange(x, y) {
+		if err := c.writeMemory(int(c.ir)+i, *c.getRegisterPointer(reg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadMemoryRange - 5XY3 loads the value of each general purpose register from VX to VY inclusive from consecutive
+// memory addresses starting from the current value of the index register. If X is greater than Y, the registers are
+// loaded in reverse order. The index register is not changed.
+func (c *Chip8) loadMemoryRange() error {
+	x := c.cir[0] & 0x0F
+	y := c.cir[1] >> 4
+	for i, reg := range registerRange(x, y) {
+		b, err := c.readMemory(int(c.ir) + i)
+		if err != nil {
+			return err
+		}
+		*c.getRegisterPointer(reg) = b
+	}
+	return nil
+}
+
+// registerRange returns the register numbers from x to y inclusive, counting down if x is greater than y
+func registerRange(x, y byte) []byte {
+	var o []byte
+	for i := x; ; {
+		o = append(o, i)
+		if i == y {
+			return o
+		}
+		if x < y {
+			i += 1
+		} else {
+			i -= 1
+		}
+	}
+}
+
+// selectPlanes - FN01 select the drawing planes in the mask N
+func (c *Chip8) selectPlanes() error {
+	c.planes = (c.cir[0] & 0x0F) & allPlanes
+	return nil
+}
+
+// loadAudioPattern - F002 load the 16 byte audio pattern from the memory location in the index register
+func (c *Chip8) loadAudioPattern() error {
+	var pattern AudioPattern
+	for i := range pattern {
+		b, err := c.readMemory(int(c.ir) + i)
+		if err != nil {
+			return err
+		}
+		pattern[i] = b
+	}
+	c.audioPattern = pattern
+	c.audioPatternLoaded = true
+	c.ui.SetAudioPattern(c.audioPattern, c.pitch)
+	return nil
+}
+
+// setPitch - FX3A set the pitch register to the value of VX
+func (c *Chip8) setPitch() error {
+	c.pitch = *c.getRegisterPointer(c.cir[0] & 0x0F)
+	if c.audioPatternLoaded {
+		c.ui.SetAudioPattern(c.audioPattern, c.pitch)
+	}
+	return nil
+}
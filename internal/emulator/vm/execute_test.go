@@ -25,11 +25,14 @@ func vmFixtureWithoutTick(instruction []byte) (*Chip8, *uid) {
 }
 
 type uid struct {
-	output *[32][64]bool
+	output *Display
 	keys [][]uint8
+
+	audioPattern *AudioPattern
+	pitch        uint8
 }
 
-func (u *uid) PublishNewDisplay(in [32][64]bool) {
+func (u *uid) PublishNewDisplay(in Display) {
 	u.output = &in
 }
 func (u *uid) GetPressedKeys() []uint8 {
@@ -42,6 +45,13 @@ func (u *uid) GetPressedKeys() []uint8 {
 }
 func (u *uid) StartTone() {}
 func (u *uid) StopTone()  {}
+func (u *uid) SetAudioPattern(pattern AudioPattern, pitch uint8) {
+	u.audioPattern = &pattern
+	u.pitch = pitch
+}
+func (u *uid) ClearAudioPattern() {
+	u.audioPattern = nil
+}
 
 func Test_ClearScreen(t *testing.T) {
 	c, u := vmFixtureWithoutTick(nil)
@@ -50,7 +60,7 @@ func Test_ClearScreen(t *testing.T) {
 
 	if u.output == nil {
 		t.Fatal("00E0 screen did not clear")
-	} else if *u.output != [32][64]bool{} {
+	} else if *u.output != (Display{}) {
 		t.Fatal("00E0 empty screen was not published")
 	}
 }
@@ -60,7 +70,7 @@ func Test_SubroutineReturn(t *testing.T) {
 
 	const newAddr = 400
 
-	c.stack.Push(newAddr)
+	c.stack.Push(newAddr, 0)
 	c.subroutineReturn()
 	if c.pc != newAddr {
 		t.Fatalf("00EE subroutine return returned to incorrect address (got %d, want %d)", c.pc, newAddr)
@@ -168,4 +178,210 @@ func Test_SetRegisterToConstant(t *testing.T) {
 	if c.v0 != regCont {
 		t.Fatalf("6XNN failed to set register correctly (got %d, want %d)", c.v0, regCont)
 	}
-}
\ No newline at end of file
+}
+
+func Test_ScrollDown(t *testing.T) {
+	c, u := vmFixtureWithoutTick([]byte{0x00, 0xC3})
+	c.disp.Pixels[0][5] = 1
+	c.disp.Pixels[31][5] = 1
+	c.tick()
+
+	if u.output.Pixels[3][5] == 0 || u.output.Pixels[0][5] != 0 {
+		t.Fatal("00CN did not move pixel down")
+	}
+	if u.output.Pixels[34][5] != 0 {
+		t.Fatal("00CN moved pixel outside of low resolution display")
+	}
+}
+
+func Test_ScrollRightLeft(t *testing.T) {
+	c, u := vmFixtureWithoutTick([]byte{0x00, 0xFB, 0x00, 0xFC, 0x00, 0xFC})
+	c.disp.Pixels[0][0] = 1
+
+	c.tick()
+	if u.output.Pixels[0][4] == 0 || u.output.Pixels[0][0] != 0 {
+		t.Fatal("00FB did not move pixel right by 4")
+	}
+
+	c.tick()
+	c.tick()
+	if *u.output != (Display{}) {
+		t.Fatal("00FC did not discard pixel moved off the display")
+	}
+}
+
+func Test_Resolution(t *testing.T) {
+	c, u := vmFixtureWithoutTick([]byte{0x00, 0xFF, 0x00, 0xFE})
+	c.disp.Pixels[0][0] = 1
+
+	c.tick()
+	if !u.output.HighRes || u.output.Width() != HighResWidth || u.output.Pixels[0][0] != 0 {
+		t.Fatal("00FF did not switch to a clear high resolution display")
+	}
+
+	c.tick()
+	if u.output.HighRes || u.output.Height() != LowResHeight {
+		t.Fatal("00FE did not switch to low resolution")
+	}
+}
+
+func Test_Exit(t *testing.T) {
+	c, _ := vmFixture([]byte{0x00, 0xFD})
+	if !c.exited {
+		t.Fatal("00FD did not exit")
+	}
+}
+
+func Test_DisplayLargeSprite(t *testing.T) {
+	c, u := vmFixtureWithoutTick([]byte{0x00, 0xFF, 0xD0, 0x10})
+	c.tick()
+
+	c.v0 = 120
+	c.ir = 0x300
+	c.memory[0x300] = 0x80
+	c.memory[0x301] = 0x01
+	c.memory[0x31E] = 0x80
+	c.tick()
+
+	if u.output.Pixels[0][120] == 0 || u.output.Pixels[15][120] == 0 {
+		t.Fatal("DXY0 did not draw 16x16 sprite")
+	}
+	if u.output.Pixels[0][7] != 0 {
+		t.Fatal("DXY0 wrapped sprite while ClipSprites is set")
+	}
+}
+
+func Test_BigFontCharacter(t *testing.T) {
+	c, _ := vmFixtureWithoutTick([]byte{0xF0, 0x30})
+	c.v0 = 0x8
+	c.tick()
+
+	for i, b := range bigFont[8] {
+		if c.memory[int(c.ir)+i] != b {
+			t.Fatalf("FX30 pointed to incorrect character (got %#x at offset %d, want %#x)", c.memory[int(c.ir)+i], i, b)
+		}
+	}
+}
+
+func Test_RPLFlags(t *testing.T) {
+	c, _ := vmFixtureWithoutTick([]byte{0xF2, 0x75, 0xF2, 0x85})
+	c.v0, c.v1, c.v2, c.v3 = 1, 2, 3, 4
+	c.tick()
+
+	c.v0, c.v1, c.v2, c.v3 = 0, 0, 0, 0
+	c.tick()
+
+	if c.v0 != 1 || c.v1 != 2 || c.v2 != 3 || c.v3 != 0 {
+		t.Fatalf("FX75/FX85 did not restore V0 to V2 (got %d %d %d %d)", c.v0, c.v1, c.v2, c.v3)
+	}
+}
+
+func Test_ScrollUp(t *testing.T) {
+	c, u := vmFixtureWithoutTick([]byte{0x00, 0xD2})
+	c.disp.Pixels[5][5] = 1
+	c.tick()
+
+	if u.output.Pixels[3][5] == 0 || u.output.Pixels[5][5] != 0 {
+		t.Fatal("00DN did not move pixel up")
+	}
+}
+
+func Test_MemoryRange(t *testing.T) {
+	c, _ := vmFixtureWithoutTick([]byte{0x53, 0x12, 0x51, 0x33})
+	c.v1, c.v2, c.v3 = 1, 2, 3
+	c.ir = 0x300
+	c.tick()
+
+	if c.memory[0x300] != 3 || c.memory[0x301] != 2 || c.memory[0x302] != 1 || c.ir != 0x300 {
+		t.Fatalf("5XY2 stored registers incorrectly (got %v, I %#x)", c.memory[0x300:0x303], c.ir)
+	}
+
+	c.v1, c.v2, c.v3 = 0, 0, 0
+	c.tick()
+
+	if c.v1 != 3 || c.v2 != 2 || c.v3 != 1 {
+		t.Fatalf("5XY3 loaded registers incorrectly (got %d %d %d)", c.v1, c.v2, c.v3)
+	}
+}
+
+func Test_SetIndexRegisterLong(t *testing.T) {
+	c, _ := vmFixture([]byte{0xF0, 0x00, 0xBE, 0xEF})
+	if c.ir != 0xBEEF || c.pc != 0x204 {
+		t.Fatalf("F000 NNNN set incorrect state (got I %#x, PC %#x)", c.ir, c.pc)
+	}
+
+	// skips should jump over the whole of a long instruction
+	c, _ = vmFixture([]byte{0x30, 0x00, 0xF0, 0x00, 0xBE, 0xEF})
+	if c.pc != 0x206 {
+		t.Fatalf("3XNN did not skip F000 NNNN (got PC %#x, want %#x)", c.pc, 0x206)
+	}
+}
+
+func Test_LargeMemory(t *testing.T) {
+	rom := make([]byte, 0x2000)
+	rom[0x1FFF] = 0xAB
+
+	c, _ := vmFixtureWithoutTick(rom)
+	if c.memory[0x21FF] != 0xAB {
+		t.Fatal("ROM larger than 4KB was not loaded")
+	}
+}
+
+func Test_Planes(t *testing.T) {
+	c, u := vmFixtureWithoutTick([]byte{0xF3, 0x01, 0xD0, 0x01, 0xF2, 0x01, 0x00, 0xE0})
+	c.ir = 0x300
+	c.memory[0x300] = 0x80 // plane 1
+	c.memory[0x301] = 0xC0 // plane 2
+
+	c.tick()
+	c.tick()
+
+	if u.output.Pixels[0][0] != 0x3 || u.output.Pixels[0][1] != 0x2 {
+		t.Fatalf("DXYN drew incorrectly with both planes selected (got %d %d)", u.output.Pixels[0][0], u.output.Pixels[0][1])
+	}
+
+	c.tick()
+	c.tick()
+
+	if u.output.Pixels[0][0] != 0x1 || u.output.Pixels[0][1] != 0x0 {
+		t.Fatalf("00E0 did not clear only the selected plane (got %d %d)", u.output.Pixels[0][0], u.output.Pixels[0][1])
+	}
+}
+
+func Test_AudioPattern(t *testing.T) {
+	c, u := vmFixtureWithoutTick([]byte{0xF0, 0x3A, 0xF0, 0x02, 0xF1, 0x3A})
+	c.v0 = 0x20
+	c.v1 = 0x70
+	c.ir = 0x300
+	c.memory[0x300] = 0xAA
+	c.memory[0x30F] = 0x01
+
+	c.tick()
+	if u.audioPattern != nil {
+		t.Fatal("FX3A published an audio pattern before one was loaded")
+	}
+
+	c.tick()
+	if u.audioPattern == nil || u.audioPattern[0] != 0xAA || u.audioPattern[15] != 0x01 || u.pitch != 0x20 {
+		t.Fatalf("F002 published incorrect pattern (got %v, pitch %d)", u.audioPattern, u.pitch)
+	}
+
+	c.tick()
+	if u.pitch != 0x70 {
+		t.Fatalf("FX3A did not update pitch (got %d, want %d)", u.pitch, 0x70)
+	}
+}
+
+func Test_PlaybackRate(t *testing.T) {
+	if r := PlaybackRate(64); r != 4000 {
+		t.Errorf("incorrect playback rate for default pitch (got %f, want 4000)", r)
+	}
+	if r := PlaybackRate(112); r != 8000 {
+		t.Errorf("incorrect playback rate for pitch 112 (got %f, want 8000)", r)
+	}
+
+	p := AudioPattern{0x80, 0x01}
+	if !p.Bit(0) || p.Bit(1) || !p.Bit(15) || !p.Bit(128) {
+		t.Errorf("incorrect pattern bits")
+	}
+}
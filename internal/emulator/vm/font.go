@@ -24,8 +24,31 @@ var font = [16][5]byte{
 	{0xF0, 0x80, 0xF0, 0x80, 0x80}, // F
 }
 
+// bigFont is the SCHIP 8x10 font used by FX30
+var bigFont = [16][10]byte{
+	{0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF}, // 0
+	{0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF}, // 1
+	{0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF}, // 2
+	{0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF}, // 3
+	{0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03}, // 4
+	{0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF}, // 5
+	{0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF}, // 6
+	{0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18}, // 7
+	{0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF}, // 8
+	{0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF}, // 9
+	{0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3}, // A
+	{0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC}, // B
+	{0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C}, // C
+	{0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC}, // D
+	{0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF}, // E
+	{0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0}, // F
+}
+
 const fontLocation uint16 = 0x50
 
+// bigFontLocation is directly after the end of the small font
+const bigFontLocation = fontLocation + uint16(len(font)*5)
+
 func loadFont(memory *memory) {
 
 	for i, letter := range font {
@@ -34,8 +57,18 @@ func loadFont(memory *memory) {
 		}
 	}
 
+	for i, letter := range bigFont {
+		for j, bt := range letter {
+			memory[int(bigFontLocation)+(i*10)+j] = bt
+		}
+	}
+
 }
 
 func getFontCharacterLocation(character byte) uint16 {
 	return fontLocation + uint16(5 * (character & 0x0F))
 }
+
+func getBigFontCharacterLocation(character byte) uint16 {
+	return bigFontLocation + uint16(10*(character&0x0F))
+}
@@ -0,0 +1,119 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/memory.go
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultMemorySize is the size of the address space of the original CHIP-8
+	DefaultMemorySize = 4 * 1024
+	// LargeMemorySize is the size of the XO-CHIP address space
+	LargeMemorySize = len(memory{})
+
+	// programStart is the address that ROMs are loaded at
+	programStart = 0x200
+
+	// DefaultStackDepth is the number of nested subroutine calls allowed by default, matching SUPER-CHIP
+	DefaultStackDepth = 16
+)
+
+// memory is large enough for the full 64KB XO-CHIP address space
+type memory [64 * 1024]byte
+
+// MemoryPolicy determines what happens when a program accesses an address outside of the address space
+type MemoryPolicy uint8
+
+const (
+	// MemoryWrap wraps out of bounds addresses around to the start of the address space
+	MemoryWrap MemoryPolicy = iota
+	// MemoryFault stops the program with ErrMemoryOutOfBounds
+	MemoryFault
+	// MemoryIgnore reads zero from out of bounds addresses and discards writes to them
+	MemoryIgnore
+)
+
+var memoryPolicyNames = map[MemoryPolicy]string{
+	MemoryWrap:   "wrap",
+	MemoryFault:  "fault",
+	MemoryIgnore: "ignore",
+}
+
+func (p MemoryPolicy) String() string {
+	if name, found := memoryPolicyNames[p]; found {
+		return name
+	}
+	return fmt.Sprintf("MemoryPolicy(%d)", uint8(p))
+}
+
+// ParseMemoryPolicy returns the memory policy with the given name (wrap, fault or ignore), ignoring case
+func ParseMemoryPolicy(name string) (MemoryPolicy, error) {
+	for p, n := range memoryPolicyNames {
+		if strings.EqualFold(n, name) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown memory policy %#v", name)
+}
+
+// memorySize returns the size of the address space, which is MemorySize limited to the size of main memory
+func (c *Chip8) memorySize() int {
+	if c.MemorySize <= 0 || c.MemorySize > len(c.memory) {
+		return len(c.memory)
+	}
+	return c.MemorySize
+}
+
+// resolveAddress applies the memory policy to addr. If ok is false, the access should not happen.
+func (c *Chip8) resolveAddress(addr int) (resolved int, ok bool, err error) {
+	size := c.memorySize()
+	if addr >= 0 && addr < size {
+		return addr, true, nil
+	}
+
+	switch c.MemoryPolicy {
+	case MemoryFault:
+		return 0, false, fmt.Errorf("%w: 0x%x", ErrMemoryOutOfBounds, addr)
+	case MemoryIgnore:
+		return 0, false, nil
+	default:
+		return addr % size, true, nil
+	}
+}
+
+// readMemory returns the byte at addr
+func (c *Chip8) readMemory(addr int) (byte, error) {
+	addr, ok, err := c.resolveAddress(addr)
+	if !ok {
+		return 0, err
+	}
+	return c.memory[addr], nil
+}
+
+// writeMemory sets the byte at addr to value
+func (c *Chip8) writeMemory(addr int, value byte) error {
+	addr, ok, err := c.resolveAddress(addr)
+	if !ok {
+		return err
+	}
+	c.memory[addr] = value
+	return nil
+}
+
+// readWord returns the big-endian 16-bit value at addr
+func (c *Chip8) readWord(addr int) (uint16, error) {
+	hi, err := c.readMemory(addr)
+	if err != nil {
+		return 0, err
+	}
+	lo, err := c.readMemory(addr + 1)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(hi)<<8 | uint16(lo), nil
+}
@@ -0,0 +1,77 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/memory_test.go
+
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_MemoryPolicy(t *testing.T) {
+	// FX55 with the index register at the last byte of the address space, storing V0 and V1
+	instruction := []byte{0xF1, 0x55}
+
+	setup := func(policy MemoryPolicy) *Chip8 {
+		c, _ := vmFixtureWithoutTick(instruction)
+		c.MemoryPolicy = policy
+		c.ir = DefaultMemorySize - 1
+		c.v0 = 0xAA
+		c.v1 = 0xBB
+		return c
+	}
+
+	t.Run("wrap", func(t *testing.T) {
+		c := setup(MemoryWrap)
+		if err := c.tick(); err != nil {
+			t.Fatal(err)
+		}
+		if c.memory[DefaultMemorySize-1] != 0xAA || c.memory[0] != 0xBB {
+			t.Fatal("out of bounds write did not wrap to the start of memory")
+		}
+	})
+
+	t.Run("fault", func(t *testing.T) {
+		c := setup(MemoryFault)
+		if err := c.tick(); !errors.Is(err, ErrMemoryOutOfBounds) {
+			t.Fatalf("out of bounds write returned %v, want %v", err, ErrMemoryOutOfBounds)
+		}
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		c := setup(MemoryIgnore)
+		if err := c.tick(); err != nil {
+			t.Fatal(err)
+		}
+		if c.memory[DefaultMemorySize-1] != 0xAA || c.memory[0] != 0x00 || c.memory[DefaultMemorySize] != 0x00 {
+			t.Fatal("out of bounds write was not ignored")
+		}
+	})
+
+	t.Run("large memory", func(t *testing.T) {
+		c := setup(MemoryFault)
+		c.MemorySize = LargeMemorySize
+		if err := c.tick(); err != nil {
+			t.Fatal(err)
+		}
+		if c.memory[DefaultMemorySize] != 0xBB {
+			t.Fatal("write beyond 4KB was not stored with a 64KB address space")
+		}
+	})
+}
+
+func Test_ParseMemoryPolicy(t *testing.T) {
+	p, err := ParseMemoryPolicy("Fault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != MemoryFault {
+		t.Fatalf("incorrect memory policy (got %s, want %s)", p, MemoryFault)
+	}
+
+	if _, err := ParseMemoryPolicy("explode"); err == nil {
+		t.Fatal("unknown memory policy did not return an error")
+	}
+}
@@ -0,0 +1,106 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/quirks.go
+
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Quirks controls behaviour that differs between CHIP-8 implementations
+type Quirks struct {
+	// CopyRegistersOnShift affects `8XY6` and `8XYE`. If true, the value of VY will be copied into VX before a shift
+	// occurs.
+	CopyRegistersOnShift bool
+	// VariableOffsetRegister affects `BNNN`. If true, `BNNN` will act as `BXNN` and will jump to NNN + VX. Else, `BNNN`
+	// will jump to NNN + V0.
+	VariableOffsetRegister bool
+	// DisableSetFlagOnIrOverflow affects `FX1E`. If true, `FX1E` will not set VF. Else, it will set VF accordingly if
+	// the index register "overflows" above 0x0FFF.
+	DisableSetFlagOnIrOverflow bool
+	// IncrementIndexRegisterOnLoadSave affects `FX55` and `FX65`. If true, the index register will be incremented when
+	// loading or saving registers to/from memory. Else, a temporary value will be indexed instead, and the index
+	// register will not be changed.
+	IncrementIndexRegisterOnLoadSave bool
+	// ResetFlagOnLogic affects `8XY1`, `8XY2` and `8XY3`. If true, VF will be set to zero after the operation.
+	ResetFlagOnLogic bool
+	// ClipSprites affects `DXYN`. If true, parts of sprites that go off the edge of the display are not drawn. Else,
+	// they wrap around to the opposite edge.
+	ClipSprites bool
+	// WaitForVBlank affects `DXYN`. If true, execution pauses after drawing a sprite until the next 60Hz timer tick.
+	WaitForVBlank bool
+}
+
+// DefaultQuirksPreset is the name of the preset used by NewChip8
+const DefaultQuirksPreset = "custom"
+
+// QuirksPresets contains the quirks of well known CHIP-8 implementations
+var QuirksPresets = map[string]Quirks{
+	// the original COSMAC VIP interpreter
+	"vip": {
+		CopyRegistersOnShift:             true,
+		VariableOffsetRegister:           false,
+		DisableSetFlagOnIrOverflow:       true,
+		IncrementIndexRegisterOnLoadSave: true,
+		ResetFlagOnLogic:                 true,
+		ClipSprites:                      true,
+		WaitForVBlank:                    true,
+	},
+	// SUPER-CHIP 1.1 on the HP48
+	"schip-legacy": {
+		CopyRegistersOnShift:             false,
+		VariableOffsetRegister:           true,
+		DisableSetFlagOnIrOverflow:       true,
+		IncrementIndexRegisterOnLoadSave: false,
+		ResetFlagOnLogic:                 false,
+		ClipSprites:                      true,
+		WaitForVBlank:                    true,
+	},
+	// SUPER-CHIP as implemented by modern interpreters such as Octo
+	"schip-modern": {
+		CopyRegistersOnShift:             false,
+		VariableOffsetRegister:           true,
+		DisableSetFlagOnIrOverflow:       true,
+		IncrementIndexRegisterOnLoadSave: false,
+		ResetFlagOnLogic:                 false,
+		ClipSprites:                      true,
+		WaitForVBlank:                    false,
+	},
+	"xo-chip": {
+		CopyRegistersOnShift:             true,
+		VariableOffsetRegister:           false,
+		DisableSetFlagOnIrOverflow:       true,
+		IncrementIndexRegisterOnLoadSave: true,
+		ResetFlagOnLogic:                 false,
+		ClipSprites:                      false,
+		WaitForVBlank:                    false,
+	},
+	// a mix of behaviours that works with most ROMs, intended as a starting point for overriding individual quirks
+	"custom": {
+		CopyRegistersOnShift:             true,
+		VariableOffsetRegister:           false,
+		DisableSetFlagOnIrOverflow:       false,
+		IncrementIndexRegisterOnLoadSave: false,
+		ResetFlagOnLogic:                 false,
+		ClipSprites:                      true,
+		WaitForVBlank:                    false,
+	},
+}
+
+// QuirksPreset returns the quirks preset with the given name
+func QuirksPreset(name string) (Quirks, error) {
+	q, found := QuirksPresets[strings.ToLower(name)]
+	if !found {
+		var names []string
+		for n := range QuirksPresets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Quirks{}, fmt.Errorf("unknown quirks preset %#v (expecting one of %s)", name, strings.Join(names, ", "))
+	}
+	return q, nil
+}
@@ -0,0 +1,95 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/quirks_test.go
+
+package vm
+
+import "testing"
+
+func Test_QuirksPreset(t *testing.T) {
+	q, err := QuirksPreset("VIP")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q != QuirksPresets["vip"] {
+		t.Errorf("preset lookup should be case insensitive")
+	}
+
+	if _, err := QuirksPreset("chip-48"); err == nil {
+		t.Errorf("expected error for unknown preset, got none")
+	}
+}
+
+func Test_QuirkCopyRegistersOnShift(t *testing.T) {
+	for _, copyRegisters := range []bool{true, false} {
+		c, _ := vmFixtureWithoutTick([]byte{0x80, 0x16})
+		c.CopyRegistersOnShift = copyRegisters
+		c.v0 = 0x08
+		c.v1 = 0x03
+		c.tick()
+
+		want := byte(0x04)
+		if copyRegisters {
+			want = 0x01
+		}
+		if c.v0 != want {
+			t.Errorf("8XY6 with CopyRegistersOnShift=%v produced incorrect result (got %#x, want %#x)", copyRegisters, c.v0, want)
+		}
+	}
+}
+
+func Test_QuirkResetFlagOnLogic(t *testing.T) {
+	for _, reset := range []bool{true, false} {
+		c, _ := vmFixtureWithoutTick([]byte{0x80, 0x11})
+		c.ResetFlagOnLogic = reset
+		c.vf = 0x01
+		c.tick()
+
+		if reset != (c.vf == 0x00) {
+			t.Errorf("8XY1 with ResetFlagOnLogic=%v left VF as %#x", reset, c.vf)
+		}
+	}
+}
+
+func Test_QuirkIncrementIndexRegisterOnLoadSave(t *testing.T) {
+	for _, increment := range []bool{true, false} {
+		c, _ := vmFixtureWithoutTick([]byte{0xF3, 0x55})
+		c.IncrementIndexRegisterOnLoadSave = increment
+		c.ir = 0x300
+		c.tick()
+
+		want := uint16(0x300)
+		if increment {
+			want = 0x304
+		}
+		if c.ir != want {
+			t.Errorf("FX55 with IncrementIndexRegisterOnLoadSave=%v left I as %#x, want %#x", increment, c.ir, want)
+		}
+	}
+}
+
+func Test_QuirkClipSprites(t *testing.T) {
+	for _, clip := range []bool{true, false} {
+		// draw the top row of the 0 character at (62, 0)
+		c, u := vmFixtureWithoutTick([]byte{0xD0, 0x11})
+		c.ClipSprites = clip
+		c.v0 = 62
+		c.ir = getFontCharacterLocation(0)
+		c.tick()
+
+		if wrapped := u.output.Pixels[0][0] != 0; wrapped == clip {
+			t.Errorf("DXYN with ClipSprites=%v drew incorrectly (wrapped pixel set: %v)", clip, wrapped)
+		}
+	}
+}
+
+func Test_QuirkWaitForVBlank(t *testing.T) {
+	c, _ := vmFixtureWithoutTick([]byte{0xD0, 0x11})
+	c.WaitForVBlank = true
+	c.tick()
+
+	if !c.waitingForVBlank {
+		t.Errorf("DXYN with WaitForVBlank did not pause execution")
+	}
+}
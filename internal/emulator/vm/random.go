@@ -0,0 +1,81 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/random.go
+
+package vm
+
+// RandomSource generates the random numbers used by CXNN. math/rand's *rand.Rand satisfies RandomSource, as do sources
+// returned by NewRandomSource.
+type RandomSource interface {
+	Uint64() uint64
+}
+
+// statefulRandomSource is a RandomSource whose state can be included in save states
+type statefulRandomSource interface {
+	RandomSource
+	state() uint64
+	setState(uint64)
+}
+
+// xorshift is a xorshift64* pseudo-random number generator. Unlike math/rand, its entire state is a single number, so
+// it can be included in save states.
+type xorshift uint64
+
+// NewRandomSource returns a RandomSource that always generates the same sequence of numbers for the same seed. Its
+// state is included in save states.
+func NewRandomSource(seed int64) RandomSource {
+	// scramble the seed with splitmix64 so that similar seeds give different sequences
+	z := uint64(seed) + 0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	z ^= z >> 31
+
+	if z == 0 { // xorshift generators never leave zero
+		z = 1
+	}
+	x := xorshift(z)
+	return &x
+}
+
+// Uint64 returns the next 64-bit number in the sequence
+func (x *xorshift) Uint64() uint64 {
+	s := uint64(*x)
+	s ^= s >> 12
+	s ^= s << 25
+	s ^= s >> 27
+	*x = xorshift(s)
+	return s * 0x2545F4914F6CDD1D
+}
+
+func (x *xorshift) state() uint64 {
+	return uint64(*x)
+}
+
+func (x *xorshift) setState(s uint64) {
+	*x = xorshift(s)
+}
+
+// SetRandomSource replaces the source of the numbers generated by CXNN. The state of sources that weren't created by
+// NewRandomSource isn't included in save states, so loading a state won't restore them. It is safe to call
+// SetRandomSource while Run is running in another goroutine.
+func (c *Chip8) SetRandomSource(src RandomSource) {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	c.rng = src
+}
+
+// rngState returns the state of the random number generator to be included in a save state
+func (c *Chip8) rngState() uint64 {
+	if s, ok := c.rng.(statefulRandomSource); ok {
+		return s.state()
+	}
+	return 0
+}
+
+// setRNGState restores the state of the random number generator from a save state
+func (c *Chip8) setRNGState(state uint64) {
+	if s, ok := c.rng.(statefulRandomSource); ok && state != 0 {
+		s.setState(state)
+	}
+}
@@ -0,0 +1,43 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/random_test.go
+
+package vm
+
+import "testing"
+
+// sequence is a RandomSource that returns the values in it in order
+type sequence []uint64
+
+func (s *sequence) Uint64() uint64 {
+	x := (*s)[0]
+	*s = (*s)[1:]
+	return x
+}
+
+func Test_Random(t *testing.T) {
+	// rand $3 0x0F
+	c, _ := vmFixtureWithoutTick([]byte{0xC3, 0x0F})
+	c.SetRandomSource(&sequence{0xABCD_0000_0000_0000})
+
+	if err := c.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if c.v3 != 0x0B {
+		t.Fatalf("CXNN set incorrect value (got %#x, want %#x)", c.v3, 0x0B)
+	}
+}
+
+func Test_NewRandomSource(t *testing.T) {
+	a, b := NewRandomSource(42), NewRandomSource(42)
+	for i := 0; i < 100; i += 1 {
+		if a.Uint64() != b.Uint64() {
+			t.Fatal("sources with the same seed generated different numbers")
+		}
+	}
+
+	if NewRandomSource(1).Uint64() == NewRandomSource(2).Uint64() {
+		t.Fatal("sources with different seeds generated the same number")
+	}
+}
@@ -0,0 +1,190 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/rewind.go
+
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// framesPerSecond is the rate of the timers, and so the rate at which rewind snapshots are taken
+const framesPerSecond = 60
+
+// rewindBuffer is a ring buffer of snapshots of the VM, one per frame.
+//
+// Only the most recent snapshot is kept in full. Every other snapshot is stored as the difference between it and the
+// snapshot after it, so that each step backwards is made by applying one delta to the snapshot after it. Consecutive
+// frames usually differ by only a few bytes, and the deltas are run-length encoded, so this uses far less memory than
+// keeping every snapshot.
+type rewindBuffer struct {
+	// latest is the most recent snapshot, encoded with encodeState
+	latest []byte
+	// spare is a snapshot that is no longer needed, kept so that its memory can be reused for the next one
+	spare []byte
+	// deltas holds the deltas in the order they were recorded, starting at start
+	deltas [][]byte
+	start  int
+	count  int
+
+	encoder deltaEncoder
+}
+
+func newRewindBuffer(frames int) *rewindBuffer {
+	return &rewindBuffer{deltas: make([][]byte, frames)}
+}
+
+// record adds snapshot to the buffer, dropping the oldest snapshot if the buffer is full. The buffer takes ownership of
+// snapshot.
+func (r *rewindBuffer) record(snapshot []byte) {
+	if r.latest != nil && len(r.deltas) != 0 {
+		i := (r.start + r.count) % len(r.deltas)
+		if r.count == len(r.deltas) {
+			r.start = (r.start + 1) % len(r.deltas)
+		} else {
+			r.count += 1
+		}
+		r.deltas[i] = r.encoder.encode(snapshot, r.latest)
+	}
+	r.latest, r.spare = snapshot, r.latest
+}
+
+// previous removes the most recent snapshot and returns the one before it, or false if there are no earlier snapshots
+func (r *rewindBuffer) previous() ([]byte, bool) {
+	if r.count == 0 {
+		return nil, false
+	}
+	r.count -= 1
+	i := (r.start + r.count) % len(r.deltas)
+	r.latest, r.spare = applyDelta(r.latest, r.deltas[i]), r.latest
+	r.deltas[i] = nil
+	return r.latest, true
+}
+
+// deltaEncoder creates deltas between snapshots, keeping its working memory between calls so that it doesn't have to be
+// allocated for every snapshot
+type deltaEncoder struct {
+	xor []byte
+	out []byte
+}
+
+// encode returns a delta that turns from into to when passed to applyDelta.
+//
+// The delta is the length of to, followed by the XOR of from and to (with the shorter one padded with zeros) as pairs
+// of runs: a run of zeros, then a run of bytes that are included literally. Run lengths are uvarints.
+func (e *deltaEncoder) encode(from, to []byte) []byte {
+	short, long := from, to
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+
+	n := len(long)
+	if cap(e.xor) < n {
+		e.xor = make([]byte, n)
+	}
+	x := e.xor[:n]
+	for i := range short {
+		x[i] = from[i] ^ to[i]
+	}
+	copy(x[len(short):], long[len(short):])
+
+	o := appendUvarint(e.out[:0], len(to))
+	for i := 0; i < n; {
+		zeros := i
+		for i < n && x[i] == 0 {
+			i += 1
+		}
+		literal := i
+		for i < n && x[i] != 0 {
+			i += 1
+		}
+
+		o = appendUvarint(o, literal-zeros)
+		o = appendUvarint(o, i-literal)
+		o = append(o, x[literal:i]...)
+	}
+	e.out = o
+
+	return append([]byte(nil), o...)
+}
+
+// appendUvarint appends x to b as a uvarint
+func appendUvarint(b []byte, x int) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(b, scratch[:binary.PutUvarint(scratch[:], uint64(x))]...)
+}
+
+// applyDelta returns the result of applying a delta created by deltaEncoder.encode to from
+func applyDelta(from, delta []byte) []byte {
+	r := bytes.NewReader(delta)
+	readUvarint := func() int {
+		// deltas are only ever created by deltaEncoder.encode, so can't be malformed
+		x, _ := binary.ReadUvarint(r)
+		return int(x)
+	}
+
+	length := readUvarint()
+	size := length
+	if len(from) > size {
+		size = len(from)
+	}
+	o := make([]byte, size)
+	copy(o, from)
+
+	i := 0
+	for r.Len() != 0 {
+		i += readUvarint()
+		literal := readUvarint()
+		for j := 0; j < literal; j += 1 {
+			b, _ := r.ReadByte()
+			o[i] ^= b
+			i += 1
+		}
+	}
+	return o[:length]
+}
+
+// EnableRewind starts recording a snapshot of the VM every frame so that execution can be played backwards with
+// RewindFrame, keeping up to the given number of seconds of history. A value of zero disables rewinding. It is safe to
+// call EnableRewind while Run is running in another goroutine.
+func (c *Chip8) EnableRewind(seconds int) {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+
+	if seconds <= 0 {
+		c.rewind = nil
+		return
+	}
+	c.rewind = newRewindBuffer(seconds * framesPerSecond)
+}
+
+// RewindFrame restores the VM to the state it was in one frame before the last snapshot was taken, returning false if
+// there is no more history or rewinding hasn't been enabled. The VM should be paused while rewinding, otherwise new
+// snapshots will be recorded as it runs. It is safe to call RewindFrame while Run is running in another goroutine.
+func (c *Chip8) RewindFrame() bool {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+
+	if c.rewind == nil {
+		return false
+	}
+
+	snapshot, ok := c.rewind.previous()
+	if !ok {
+		return false
+	}
+
+	// snapshots are only ever created by encodeState, so can always be decoded
+	state, stack, _ := decodeState(snapshot)
+	c.restore(state, stack)
+	return true
+}
+
+// recordFrame takes a rewind snapshot, if rewinding is enabled
+func (c *Chip8) recordFrame() {
+	if c.rewind != nil {
+		c.rewind.record(c.encodeState(c.rewind.spare))
+	}
+}
@@ -0,0 +1,92 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/rewind_test.go
+
+package vm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_Delta(t *testing.T) {
+	cases := []struct {
+		from, to []byte
+	}{
+		{[]byte{1, 2, 3, 4}, []byte{1, 2, 3, 4}},
+		{[]byte{1, 2, 3, 4}, []byte{1, 9, 3, 8}},
+		{[]byte{1, 2, 3, 4}, []byte{1, 2}},
+		{[]byte{1, 2}, []byte{5, 2, 3, 4}},
+		{nil, []byte{1}},
+		{[]byte{0, 0, 0}, nil},
+	}
+
+	// the same encoder is used for every case, to check that reusing its memory doesn't affect the deltas
+	var e deltaEncoder
+	for _, c := range cases {
+		delta := e.encode(c.from, c.to)
+		if got := applyDelta(c.from, delta); !bytes.Equal(got, c.to) {
+			t.Errorf("delta from %v to %v gave %v", c.from, c.to, got)
+		}
+	}
+
+	// identical snapshots should compress to almost nothing
+	big := make([]byte, 70000)
+	if n := len(e.encode(big, big)); n > 8 {
+		t.Errorf("delta between identical snapshots is %d bytes long", n)
+	}
+}
+
+func Test_RewindFrame(t *testing.T) {
+	c, _ := vmFixtureWithoutTick(stateTestROM)
+	c.EnableRewind(1)
+
+	if c.RewindFrame() {
+		t.Fatal("rewound with no history")
+	}
+
+	var states []*machineState
+	for i := 0; i < framesPerSecond*2; i += 1 {
+		if err := c.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, c.state())
+	}
+
+	// only one second of history is kept, and the most recent snapshot is the current state
+	for i := len(states) - 2; i >= len(states)-1-framesPerSecond; i -= 1 {
+		if !c.RewindFrame() {
+			t.Fatalf("ran out of history at frame %d", i)
+		}
+		if *c.state() != *states[i] {
+			t.Fatalf("rewound state does not match the state after frame %d", i)
+		}
+	}
+
+	if c.RewindFrame() {
+		t.Fatal("rewound further than the configured history")
+	}
+
+	// execution continues normally after rewinding
+	if err := c.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if *c.state() != *states[len(states)-framesPerSecond] {
+		t.Fatal("execution after rewinding differs from the original execution")
+	}
+}
+
+func Benchmark_RecordFrame(b *testing.B) {
+	c, _ := vmFixtureWithoutTick(stateTestROM)
+	c.EnableRewind(10)
+	if err := c.RunFrame(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		c.recordFrame()
+	}
+}
@@ -5,15 +5,26 @@
 
 package vm
 
-// Stack is a unlimited size, 16-bit, FIFO stack
+// Stack is a 16-bit, FIFO stack
 type Stack []uint16
 
-func (s *Stack) Push(cont uint16) {
+// Push adds cont to the top of the stack. If the stack already holds limit values, ErrStackOverflow is returned. A limit
+// of zero means the stack is unlimited.
+func (s *Stack) Push(cont uint16, limit int) error {
+	if limit != 0 && len(*s) >= limit {
+		return ErrStackOverflow
+	}
 	*s = append(*s, cont)
+	return nil
 }
 
-func (s *Stack) Pop() uint16 {
+// Pop removes the most recently pushed value from the stack and returns it. If the stack is empty, ErrStackUnderflow is
+// returned.
+func (s *Stack) Pop() (uint16, error) {
+	if len(*s) == 0 {
+		return 0, ErrStackUnderflow
+	}
 	var x uint16
 	x, *s = (*s)[len(*s)-1], (*s)[:len(*s)-1]
-	return x
+	return x, nil
 }
\ No newline at end of file
@@ -0,0 +1,353 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/state.go
+
+package vm
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// stateMagic is the first four bytes of every save state
+var stateMagic = [4]byte{'C', '8', 'S', 'T'}
+
+// stateVersion is the version of the save state format written by SaveState. It must be increased whenever the
+// layout written by encodeState changes, including when fields are added to Quirks.
+const stateVersion uint16 = 3
+
+var (
+	ErrNotState         = errors.New("not a save state")
+	ErrStateVersion     = errors.New("unsupported save state version")
+	ErrStateROMMismatch = errors.New("save state was made with a different ROM")
+)
+
+// stateHeader is written at the start of every save state
+type stateHeader struct {
+	Magic   [4]byte
+	Version uint16
+	ROMHash [sha1.Size]byte
+}
+
+// machineState is the complete state of a Chip8, excluding the call stack, as read from an encoded state
+type machineState struct {
+	Quirks        Quirks
+	MemoryPolicy  MemoryPolicy
+	MemorySize    uint32
+	MaxStackDepth uint32
+
+	WaitingForVBlank bool
+	Exited           bool
+
+	Memory memory
+
+	CIR   [2]byte
+	PC    uint16
+	IR    uint16
+	Delay uint8
+	Sound uint8
+	Keys  uint16
+
+	Planes             uint8
+	AudioPattern       AudioPattern
+	AudioPatternLoaded bool
+	Pitch              uint8
+
+	RPL [16]byte
+	V   [16]byte
+
+	Display Display
+
+	// RNG is the state of the random number generator, or zero if it can't be saved
+	RNG uint64
+}
+
+// SaveState writes the complete state of the VM to w, so that it can be restored later with LoadState. It is safe to
+// call SaveState while Run is running in another goroutine.
+func (c *Chip8) SaveState(w io.Writer) error {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+
+	header := stateHeader{
+		Magic:   stateMagic,
+		Version: stateVersion,
+		ROMHash: sha1.Sum(c.rom),
+	}
+	if err := binary.Write(w, binary.BigEndian, &header); err != nil {
+		return err
+	}
+
+	_, err := w.Write(c.encodeState(nil))
+	return err
+}
+
+// LoadState restores a state written by SaveState. The state must have been saved while running the same ROM. If an
+// error is returned, the VM is left unchanged. It is safe to call LoadState while Run is running in another goroutine.
+func (c *Chip8) LoadState(r io.Reader) error {
+	var header stateHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotState, err)
+	}
+	if header.Magic != stateMagic {
+		return ErrNotState
+	}
+	if header.Version != stateVersion {
+		return fmt.Errorf("%w %d", ErrStateVersion, header.Version)
+	}
+	if header.ROMHash != sha1.Sum(c.rom) {
+		return ErrStateROMMismatch
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	state, stack, err := decodeState(b)
+	if err != nil {
+		return err
+	}
+
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+
+	c.restore(state, stack)
+
+	return nil
+}
+
+// encodeState appends the state of the VM, including the stack, to b[:0] in the format used by save states and
+// returns the result. Only the memory inside the address space is included. Passing the slice returned by a previous
+// call as b avoids allocating a new one every time.
+func (c *Chip8) encodeState(b []byte) []byte {
+	w := &stateWriter{b: b[:0]}
+
+	w.bool(c.CopyRegistersOnShift)
+	w.bool(c.VariableOffsetRegister)
+	w.bool(c.DisableSetFlagOnIrOverflow)
+	w.bool(c.IncrementIndexRegisterOnLoadSave)
+	w.bool(c.ResetFlagOnLogic)
+	w.bool(c.ClipSprites)
+	w.bool(c.WaitForVBlank)
+	w.uint8(uint8(c.MemoryPolicy))
+	w.uint32(uint32(c.MemorySize))
+	w.uint32(uint32(c.MaxStackDepth))
+
+	w.bool(c.waitingForVBlank)
+	w.bool(c.exited)
+
+	copy(w.next(len(c.cir)), c.cir[:])
+	w.uint16(c.pc)
+	w.uint16(c.ir)
+	w.uint8(c.delay)
+	w.uint8(c.sound)
+	w.uint16(c.keys)
+
+	w.uint8(c.planes)
+	copy(w.next(len(c.audioPattern)), c.audioPattern[:])
+	w.bool(c.audioPatternLoaded)
+	w.uint8(c.pitch)
+
+	copy(w.next(len(c.rpl)), c.rpl[:])
+	for i := 0; i < 16; i += 1 {
+		w.uint8(*c.getRegisterPointer(byte(i)))
+	}
+
+	w.uint64(c.rngState())
+
+	w.bool(c.disp.HighRes)
+	for _, row := range c.disp.Pixels {
+		copy(w.next(len(row)), row[:])
+	}
+
+	mem := c.memory[:c.memorySize()]
+	w.uint32(uint32(len(mem)))
+	copy(w.next(len(mem)), mem)
+
+	w.uint16(uint16(len(c.stack)))
+	for _, v := range c.stack {
+		w.uint16(v)
+	}
+
+	return w.b
+}
+
+// decodeState reads a state written by encodeState
+func decodeState(b []byte) (*machineState, []uint16, error) {
+	r := &stateReader{b: b}
+	s := new(machineState)
+
+	s.Quirks.CopyRegistersOnShift = r.bool()
+	s.Quirks.VariableOffsetRegister = r.bool()
+	s.Quirks.DisableSetFlagOnIrOverflow = r.bool()
+	s.Quirks.IncrementIndexRegisterOnLoadSave = r.bool()
+	s.Quirks.ResetFlagOnLogic = r.bool()
+	s.Quirks.ClipSprites = r.bool()
+	s.Quirks.WaitForVBlank = r.bool()
+	s.MemoryPolicy = MemoryPolicy(r.uint8())
+	s.MemorySize = r.uint32()
+	s.MaxStackDepth = r.uint32()
+
+	s.WaitingForVBlank = r.bool()
+	s.Exited = r.bool()
+
+	copy(s.CIR[:], r.next(len(s.CIR)))
+	s.PC = r.uint16()
+	s.IR = r.uint16()
+	s.Delay = r.uint8()
+	s.Sound = r.uint8()
+	s.Keys = r.uint16()
+
+	s.Planes = r.uint8()
+	copy(s.AudioPattern[:], r.next(len(s.AudioPattern)))
+	s.AudioPatternLoaded = r.bool()
+	s.Pitch = r.uint8()
+
+	copy(s.RPL[:], r.next(len(s.RPL)))
+	copy(s.V[:], r.next(len(s.V)))
+
+	s.RNG = r.uint64()
+
+	s.Display.HighRes = r.bool()
+	for i := range s.Display.Pixels {
+		copy(s.Display.Pixels[i][:], r.next(len(s.Display.Pixels[i])))
+	}
+
+	memLength := int(r.uint32())
+	if memLength > len(s.Memory) {
+		return nil, nil, fmt.Errorf("%w: memory is %d bytes long", ErrNotState, memLength)
+	}
+	copy(s.Memory[:], r.next(memLength))
+
+	stack := make([]uint16, r.uint16())
+	for i := range stack {
+		stack[i] = r.uint16()
+	}
+
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	return s, stack, nil
+}
+
+// stateWriter appends the fields of an encoded state to a byte slice
+type stateWriter struct {
+	b []byte
+}
+
+// next extends the slice by n bytes and returns them to be written to
+func (w *stateWriter) next(n int) []byte {
+	w.b = append(w.b, make([]byte, n)...)
+	return w.b[len(w.b)-n:]
+}
+
+func (w *stateWriter) bool(v bool) {
+	if v {
+		w.uint8(1)
+	} else {
+		w.uint8(0)
+	}
+}
+
+func (w *stateWriter) uint8(v uint8) {
+	w.next(1)[0] = v
+}
+
+func (w *stateWriter) uint16(v uint16) {
+	binary.BigEndian.PutUint16(w.next(2), v)
+}
+
+func (w *stateWriter) uint32(v uint32) {
+	binary.BigEndian.PutUint32(w.next(4), v)
+}
+
+func (w *stateWriter) uint64(v uint64) {
+	binary.BigEndian.PutUint64(w.next(8), v)
+}
+
+// stateReader reads the fields of an encoded state from a byte slice. If the slice is too short, err is set and zeros
+// are read instead.
+type stateReader struct {
+	b   []byte
+	err error
+}
+
+// next consumes n bytes and returns them
+func (r *stateReader) next(n int) []byte {
+	if len(r.b) < n {
+		r.b = nil
+		r.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *stateReader) bool() bool {
+	return r.uint8() != 0
+}
+
+func (r *stateReader) uint8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *stateReader) uint16() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *stateReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *stateReader) uint64() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+// restore sets the state of the VM to s and stack, and brings the UI up to date
+func (c *Chip8) restore(s *machineState, stack []uint16) {
+	c.Quirks = s.Quirks
+	c.MemoryPolicy = s.MemoryPolicy
+	c.MemorySize = int(s.MemorySize)
+	c.MaxStackDepth = int(s.MaxStackDepth)
+
+	c.waitingForVBlank = s.WaitingForVBlank
+	c.exited = s.Exited
+
+	c.memory = s.Memory
+
+	c.cir = s.CIR
+	c.pc = s.PC
+	c.ir = s.IR
+	c.stack = append(Stack(nil), stack...)
+	c.delay = s.Delay
+	c.sound = s.Sound
+	c.keys = s.Keys
+
+	c.planes = s.Planes
+	c.audioPattern = s.AudioPattern
+	c.audioPatternLoaded = s.AudioPatternLoaded
+	c.pitch = s.Pitch
+
+	c.rpl = s.RPL
+	for i, v := range s.V {
+		*c.getRegisterPointer(byte(i)) = v
+	}
+
+	c.disp = s.Display
+
+	c.setRNGState(s.RNG)
+
+	c.ui.PublishNewDisplay(c.disp)
+	if c.audioPatternLoaded {
+		c.ui.SetAudioPattern(c.audioPattern, c.pitch)
+	} else {
+		c.ui.ClearAudioPattern()
+	}
+	if c.sound == 0 {
+		c.ui.StopTone()
+	}
+}
@@ -0,0 +1,158 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/state_test.go
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// stateTestROM draws random sprites at random positions from inside a subroutine, forever
+var stateTestROM = []byte{
+	0x22, 0x04, // call 0x204
+	0x12, 0x00, // jmp 0x200
+	0xC0, 0x3F, // rand $0 0x3F
+	0xC1, 0x1F, // rand $1 0x1F
+	0xC2, 0x0F, // rand $2 0x0F
+	0xF2, 0x29, // char $2
+	0xD0, 0x15, // disp $0 $1 5
+	0xF2, 0x15, // dset $2
+	0x00, 0xEE, // rtn
+}
+
+// state returns a copy of the state of the VM, excluding the contents of the stack
+func (c *Chip8) state() *machineState {
+	s := &machineState{
+		Quirks:        c.Quirks,
+		MemoryPolicy:  c.MemoryPolicy,
+		MemorySize:    uint32(c.MemorySize),
+		MaxStackDepth: uint32(c.MaxStackDepth),
+
+		WaitingForVBlank: c.waitingForVBlank,
+		Exited:           c.exited,
+
+		Memory: c.memory,
+
+		CIR:   c.cir,
+		PC:    c.pc,
+		IR:    c.ir,
+		Delay: c.delay,
+		Sound: c.sound,
+		Keys:  c.keys,
+
+		Planes:             c.planes,
+		AudioPattern:       c.audioPattern,
+		AudioPatternLoaded: c.audioPatternLoaded,
+		Pitch:              c.pitch,
+
+		RPL: c.rpl,
+
+		Display: c.disp,
+
+		RNG: c.rngState(),
+	}
+	for i := range s.V {
+		s.V[i] = *c.getRegisterPointer(byte(i))
+	}
+	return s
+}
+
+func Test_StateRoundTrip(t *testing.T) {
+	c, u := vmFixtureWithoutTick(stateTestROM)
+	c.Quirks = QuirksPresets["vip"]
+	c.WaitForVBlank = false
+	c.MemoryPolicy = MemoryIgnore
+
+	for i := 0; i < 5; i += 1 {
+		if err := c.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.RunCycles(3); err != nil { // stop part way through the subroutine, after 43 instructions
+		t.Fatal(err)
+	}
+
+	saved := new(bytes.Buffer)
+	if err := c.SaveState(saved); err != nil {
+		t.Fatal(err)
+	}
+	want := c.state()
+
+	// only the memory inside the address space is saved
+	if saved.Len() > DefaultMemorySize+HighResWidth*HighResHeight+1024 {
+		t.Fatalf("save state is too large (%d bytes)", saved.Len())
+	}
+
+	// continue running, so that restoring has to undo everything
+	for i := 0; i < 5; i += 1 {
+		if err := c.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Quirks = QuirksPresets["schip-modern"]
+	c.MemoryPolicy = MemoryFault
+	afterWant := c.state()
+
+	if err := c.LoadState(bytes.NewReader(saved.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.state(); *got != *want {
+		t.Fatal("restored state does not match saved state")
+	}
+	if len(c.stack) != 1 {
+		t.Fatalf("restored stack has incorrect depth (got %d, want 1)", len(c.stack))
+	}
+	if u.output == nil || *u.output != want.Display {
+		t.Fatal("restored display was not published")
+	}
+
+	// the RNG state is restored too, so running again from the restored state should be identical
+	for i := 0; i < 5; i += 1 {
+		if err := c.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Quirks = QuirksPresets["schip-modern"]
+	c.MemoryPolicy = MemoryFault
+	if got := c.state(); *got != *afterWant {
+		t.Fatal("execution after restoring differs from the original execution")
+	}
+}
+
+func Test_LoadStateErrors(t *testing.T) {
+	c, _ := vmFixtureWithoutTick(stateTestROM)
+	saved := new(bytes.Buffer)
+	if err := c.SaveState(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := vmFixtureWithoutTick([]byte{0x12, 0x00})
+	if err := other.LoadState(bytes.NewReader(saved.Bytes())); !errors.Is(err, ErrStateROMMismatch) {
+		t.Fatalf("loading a state for a different ROM returned %v, want %v", err, ErrStateROMMismatch)
+	}
+
+	if err := c.LoadState(bytes.NewReader([]byte("not a state at all, not even close"))); !errors.Is(err, ErrNotState) {
+		t.Fatalf("loading garbage returned %v, want %v", err, ErrNotState)
+	}
+
+	b := saved.Bytes()
+	b[5] += 1 // version
+	if err := c.LoadState(bytes.NewReader(b)); !errors.Is(err, ErrStateVersion) {
+		t.Fatalf("loading a state with an unknown version returned %v, want %v", err, ErrStateVersion)
+	}
+
+	before := c.state()
+	truncated := saved.Bytes()
+	truncated[5] -= 1
+	if err := c.LoadState(bytes.NewReader(truncated[:len(truncated)/2])); err == nil {
+		t.Fatal("loading a truncated state did not return an error")
+	}
+	if *c.state() != *before {
+		t.Fatal("failing to load a state changed the VM")
+	}
+}
@@ -6,39 +6,63 @@
 package vm
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
+	"github.com/codemicro/chip8/internal/instructions"
+	"sync"
 	"time"
 )
 
-type memory [4 * 1024]byte
+// InputSource provides the state of the keypad
+type InputSource interface {
+	// GetPressedKeys returns the keypad keys that are currently pressed. It is called once per frame.
+	GetPressedKeys() []uint8
+}
 
 type uiDriver interface {
-	PublishNewDisplay([32][64]bool)
-	GetPressedKeys() []uint8
+	PublishNewDisplay(Display)
+	InputSource
 	StartTone()
 	StopTone()
+	// SetAudioPattern is called when an XO-CHIP program loads an audio pattern or changes the pitch register after
+	// loading one. From then on, the pattern should be played instead of the default tone.
+	SetAudioPattern(pattern AudioPattern, pitch uint8)
+	// ClearAudioPattern is called when the VM is reset, after which the default tone should be played again
+	ClearAudioPattern()
 }
 
 type Chip8 struct {
 	Debug bool
 
-	// CopyRegistersOnShift affects `8XY6` and `8XYE`. If true, the value of VY will be copied into VX before a shift
-	// occurs.
-	CopyRegistersOnShift bool
-	// VariableOffsetRegister affects `BNNN`. If true, `BNNN` will act as `BXNN` and will jump to NNN + VX. Else, `BNNN`
-	// will jump to NNN + V0.
-	VariableOffsetRegister bool
-	// DisableSetFlagOnIrOverflow affects `FX1E`. If true, `FX1E` will not set VF. Else, it will set VF accordingly if
-	// the index register "overflows" above 0x0FFF.
-	DisableSetFlagOnIrOverflow bool
-	// IncrementIndexRegisterOnLoadSave affects `FX55` and `FX65`. If true, the index register will be incremented when
-	// loading or saving registers to/from memory. Else, a temporary value will be indexed instead, and the index
-	// register will not be changed.
-	IncrementIndexRegisterOnLoadSave bool
+	Quirks
+
+	// MemoryPolicy determines what happens when the program accesses an address outside of the first MemorySize bytes
+	// of memory
+	MemoryPolicy MemoryPolicy
+	// MemorySize is the size of the address space, up to LargeMemorySize
+	MemorySize int
+	// MaxStackDepth is the maximum number of nested subroutine calls. If it is zero, the stack is unlimited.
+	MaxStackDepth int
+
+	// waitingForVBlank is set when execution is paused until the next timer tick because of WaitForVBlank
+	waitingForVBlank bool
+	// exited is set when the program has finished by executing `00FD`
+	exited bool
+
+	// runLock is held by Run while it executes instructions, so that Pause, Resume, SetClockSpeed and Reset can be
+	// called from other goroutines
+	runLock sync.Mutex
+	// paused is set by Pause and cleared by Resume
+	paused bool
 
 	ui              uiDriver
+	input           InputSource
 	clockSpeedHertz int
-	disp            [32][64]bool
+	disp            Display
+
+	// rom is kept so that the program can be reloaded by Reset
+	rom []byte
 
 	// Main memory
 	memory memory
@@ -51,6 +75,26 @@ type Chip8 struct {
 	delay uint8
 	sound uint8
 
+	// keys is a mask of the keypad keys that were pressed at the start of the current frame, where bit N is key N
+	keys uint16
+
+	// planes is a mask of the XO-CHIP drawing planes that are affected by drawing, clearing and scrolling
+	planes uint8
+
+	// XO-CHIP audio
+	audioPattern       AudioPattern
+	audioPatternLoaded bool
+	pitch              uint8
+
+	// rpl holds the SCHIP RPL user flags, which are named after the HP48 calculator's registers
+	rpl [16]byte
+
+	// rng generates the numbers used by CXNN
+	rng RandomSource
+
+	// rewind holds snapshots of previous frames, if rewinding has been enabled with EnableRewind
+	rewind *rewindBuffer
+
 	// General purpose registers
 	v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, va, vb, vc, vd, ve, vf byte
 }
@@ -59,37 +103,69 @@ func NewChip8(rom []byte, ui uiDriver, clockSpeedHertz int) *Chip8 {
 
 	c := &Chip8{
 		ui:              ui,
+		input:           ui,
 		clockSpeedHertz: clockSpeedHertz,
+		rom:             rom,
+		rng:             NewRandomSource(time.Now().UnixNano()),
 
-		pc: 0x200,
+		MemorySize:    DefaultMemorySize,
+		MaxStackDepth: DefaultStackDepth,
 	}
 
+	c.load()
+
+	c.Quirks = QuirksPresets[DefaultQuirksPreset]
+
+	return c
+}
+
+// load puts the VM into its power-on state, with the ROM and fonts in memory. Settings such as Quirks are not changed.
+func (c *Chip8) load() {
+	c.waitingForVBlank = false
+	c.exited = false
+	c.disp = Display{}
+	c.memory = memory{}
+
+	c.cir = [2]byte{}
+	c.pc = programStart
+	c.ir = 0
+	c.stack = nil
+	c.delay = 0
+	c.sound = 0
+	c.keys = 0
+
+	c.planes = 0x01
+	c.audioPattern = AudioPattern{}
+	c.audioPatternLoaded = false
+	c.pitch = defaultPitch
+	c.rpl = [16]byte{}
+
+	c.v0, c.v1, c.v2, c.v3, c.v4, c.v5, c.v6, c.v7 = 0, 0, 0, 0, 0, 0, 0, 0
+	c.v8, c.v9, c.va, c.vb, c.vc, c.vd, c.ve, c.vf = 0, 0, 0, 0, 0, 0, 0, 0
+
 	// load ROM
-	for i, b := range rom {
-		c.memory[int(c.pc) + i] = b
-	}
+	copy(c.memory[c.pc:], c.rom)
 
 	loadFont(&c.memory)
+}
 
-	// original COSMAC VIP settings
-	//c.CopyRegistersOnShift = true
-	//c.VariableOffsetRegister = false
-	//c.DisableSetFlagOnIrOverflow = true
-	//c.IncrementIndexRegisterOnLoadSave = true
-
-	// Super Chip settings
-	//c.CopyRegistersOnShift = false
-	//c.VariableOffsetRegister = true
-	//c.DisableSetFlagOnIrOverflow = false
-	//c.IncrementIndexRegisterOnLoadSave = false
+// Reset restarts the program from the beginning, as if the VM had just been created. The display is cleared and any
+// sound is stopped. Reset is safe to call while Run is running in another goroutine.
+func (c *Chip8) Reset() {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
 
-	// best compatibility mode? I think?
-	c.CopyRegistersOnShift = true
-	c.VariableOffsetRegister = false
-	c.DisableSetFlagOnIrOverflow = false
-	c.IncrementIndexRegisterOnLoadSave = false
+	c.load()
+	c.ui.StopTone()
+	c.ui.ClearAudioPattern()
+	c.ui.PublishNewDisplay(c.disp)
+}
 
-	return c
+// Exited returns true if the program has finished by executing `00FD`
+func (c *Chip8) Exited() bool {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	return c.exited
 }
 
 // decrement decrements *v by 1 if it's not zero
@@ -99,15 +175,25 @@ func decrement(v *uint8) {
 	}
 }
 
-func (c *Chip8) fetchNext() {
-	c.cir[0] = c.memory[c.pc]
-	c.cir[1] = c.memory[c.pc+1]
+func (c *Chip8) fetchNext() error {
+	opcode, err := c.readWord(int(c.pc))
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(c.cir[:], opcode)
 	c.pc += 2
+	return nil
 }
 
-func (c *Chip8) tick() {
+// tick executes a single instruction. If the instruction can't be executed, an *ExecError is returned and the state of
+// the VM is left as it was when the error occurred.
+func (c *Chip8) tick() error {
+	pc := c.pc
+
 	// FETCH
-	c.fetchNext()
+	if err := c.fetchNext(); err != nil {
+		return &ExecError{PC: pc, Reason: err}
+	}
 
 	if c.Debug {
 		fmt.Printf("DEBUG: pc:0x%04x cir:0x%04x\n", c.pc - 2, c.cir)
@@ -119,189 +205,276 @@ func (c *Chip8) tick() {
 	}
 
 	// DECODE + EXECUTE
-	switch c.cir[0] & 0xF0 { // first four bits
-	case 0x00:
-
-		switch c.cir {
-		case [2]byte{0x00, 0xE0}:
-			// 00E0 - clear screen
-			c.clearScreen()
-		case [2]byte{0x00, 0xEE}:
-			// 00EE - subroutine return
-			c.subroutineReturn()
-		default:
-			panic(fmt.Errorf("UNHANDLED at %x: %x\n", c.pc, c.cir))
-		}
+	opcode := binary.BigEndian.Uint16(c.cir[:])
+	def, found := instructions.Decode(opcode)
+	if !found {
+		return &ExecError{PC: pc, Opcode: opcode, Reason: ErrUnknownOpcode}
+	}
+	if err := executors[def.Mnemonic](c); err != nil {
+		return &ExecError{PC: pc, Opcode: opcode, Reason: err}
+	}
+	return nil
+}
 
-	case 0x10:
-		// 1NNN - jump
-		c.jump()
-
-	case 0x20:
-		// 2NNN - subroutine call
-		c.subroutineCall()
-
-	case 0x30:
-		// 3XNN - skip one if register equal to constant
-		c.skipEqRegConst()
-
-	case 0x40:
-		// 4XNN - skip one if register not equal to constant
-		c.skipNotEqRegConst()
-
-	case 0x50:
-		// 5XY0 - skip one if registers equal
-		c.skipEqRegReg()
-
-	case 0x60:
-		// 6XNN - set VX to NN
-		c.setRegisterToConstant()
-
-	case 0x70:
-		// 7XNN - add NN to VX without setting carry flag
-		c.addConstantToRegister()
-
-	case 0x80:
-		// Logical and arithmetic instructions
-		switch c.cir[1] & 0x0F { // switch on the last four bits - eg 00000000 00001111
-		case 0x00:
-			// 8XY0 - set VX to VY
-			c.setRegisterToRegister()
-		case 0x01:
-			// 8XY1 - set VX to logical OR of VX and VY
-			c.setRegisterToLogicalOr()
-		case 0x02:
-			// 8XY2 - set VX to logical AND of VX and VY
-			c.setRegisterToLogicalAnd()
-		case 0x03:
-			// 8XY3 - set VX to logical XOR of VX and VY
-			c.setRegisterToLogicalXor()
-		case 0x04:
-			// 8XY4 - set VX to the sum of VX and VY then set the carry flag as appropriate
-			c.setRegisterToSum()
-		case 0x05:
-			// 8XY5 - set VX to VX - VY then set the carry flag as appropriate
-			c.setRegisterToDifferenceA()
-		case 0x06:
-			// 8XY6 - set VX to VY (if CopyRegistersOnShift), shift the value of VX 1 bit right and set VF to
-			// the bit shifted out
-			c.shiftRight()
-		case 0x07:
-			// 8XY7 - set VX to VY - VX then set the carry flag as appropriate
-			c.setRegisterToDifferenceB()
-		case 0x0E:
-			// 8XYE - set VX to VY (if CopyRegistersOnShift), shift the value of VX 1 bit left and set VF to the
-			// bit shifted out
-			c.shiftLeft()
-		default:
-			panic(fmt.Errorf("UNHANDLED at %x: %x\n", c.pc, c.cir))
-		}
+// executors maps each mnemonic in the instruction set to the function that executes it
+var executors = map[string]func(c *Chip8) error{
+	// 00E0 - clear screen
+	"clr": (*Chip8).clearScreen,
+	// 00EE - subroutine return
+	"rtn": (*Chip8).subroutineReturn,
+	// 00CN - scroll display N pixels down
+	"scrd": (*Chip8).scrollDown,
+	// 00DN - scroll display N pixels up
+	"scru": (*Chip8).scrollUp,
+	// 00FB - scroll display 4 pixels right
+	"scrr": (*Chip8).scrollRight,
+	// 00FC - scroll display 4 pixels left
+	"scrl": (*Chip8).scrollLeft,
+	// 00FD - exit the interpreter
+	"exit": (*Chip8).exit,
+	// 00FE - switch to low resolution mode
+	"lres": (*Chip8).lowRes,
+	// 00FF - switch to high resolution mode
+	"hres": (*Chip8).highRes,
+	// 1NNN - jump
+	"jmp": (*Chip8).jump,
+	// 2NNN - subroutine call
+	"call": (*Chip8).subroutineCall,
+	// 3XNN - skip one if register equal to constant
+	"src": (*Chip8).skipEqRegConst,
+	// 4XNN - skip one if register not equal to constant
+	"srcx": (*Chip8).skipNotEqRegConst,
+	// 5XY0 - skip one if registers equal
+	"srr": (*Chip8).skipEqRegReg,
+	// 9XY0 - skip one if registers not equal
+	"srrx": (*Chip8).skipNotEqRegReg,
+	// 5XY2 - store the value of each general purpose register from VX to VY inclusive in consecutive memory addresses
+	// starting from the current value of the index register
+	"loadr": (*Chip8).storeMemoryRange,
+	// 5XY3 - loads the value of each general purpose register from VX to VY inclusive from consecutive memory
+	// addresses starting from the current value of the index register
+	"saver": (*Chip8).loadMemoryRange,
+	// 6XNN - set VX to NN
+	"set": (*Chip8).setRegisterToConstant,
+	// 7XNN - add NN to VX without setting carry flag
+	"add": (*Chip8).addConstantToRegister,
+	// 8XY0 - set VX to VY
+	"copy": (*Chip8).setRegisterToRegister,
+	// 8XY1 - set VX to logical OR of VX and VY
+	"or": (*Chip8).setRegisterToLogicalOr,
+	// 8XY2 - set VX to logical AND of VX and VY
+	"and": (*Chip8).setRegisterToLogicalAnd,
+	// 8XY3 - set VX to logical XOR of VX and VY
+	"xor": (*Chip8).setRegisterToLogicalXor,
+	// 8XY4 - set VX to the sum of VX and VY then set the carry flag as appropriate
+	"sum": (*Chip8).setRegisterToSum,
+	// 8XY5 - set VX to VX - VY then set the carry flag as appropriate
+	"sub": (*Chip8).setRegisterToDifferenceA,
+	// 8XY7 - set VX to VY - VX then set the carry flag as appropriate
+	"bsub": (*Chip8).setRegisterToDifferenceB,
+	// 8XY6 - set VX to VY (if CopyRegistersOnShift), shift the value of VX 1 bit right and set VF to the bit shifted
+	// out
+	"rsh": (*Chip8).shiftRight,
+	// 8XYE - set VX to VY (if CopyRegistersOnShift), shift the value of VX 1 bit left and set VF to the bit shifted out
+	"lsh": (*Chip8).shiftLeft,
+	// ANNN - set index register to constant
+	"idx": (*Chip8).setIndexRegister,
+	// F000 NNNN - set index register to the 16-bit constant in the following two bytes
+	"idxl": (*Chip8).setIndexRegisterLong,
+	// FX1E - adds the value of VX to the index register and set VF accordingly if the index register "overflows" above
+	// 0x0FFF
+	"idxs": (*Chip8).addToIndexRegister,
+	// BNNN - set PC to NNN + V0 - if VariableOffsetRegister, BXNN - set PC to XNN + VX
+	"jmpo": (*Chip8).jumpWithOffset,
+	// CXNN - generate a random byte, AND it with NN and store in VX
+	"rand": (*Chip8).random,
+	// DXYN - display
+	"disp": (*Chip8).display,
+	// EX9E - skip one if key with the value stored in VX is pressed
+	"skp": (*Chip8).skipIfKey,
+	// EXA1 - skip one if key with the value stored in VX is not pressed
+	"skpx": (*Chip8).skipIfNotKey,
+	// FX0A - blocks until a key is pressed. Stores that key's value in VX then continues.
+	"inp": (*Chip8).getPressedKey,
+	// FX07 - set value of VX to the current value of the delay timer
+	"dget": (*Chip8).getDelayTimer,
+	// FX15 - set delay timer to value of VX
+	"dset": (*Chip8).setDelayTimer,
+	// FX18 - set sound timer to the value of VX
+	"sset": (*Chip8).setSoundTimer,
+	// FX29 - set the index register to the address of the hex character in VX
+	"char": (*Chip8).getFontCharacter,
+	// FX30 - set the index register to the address of the large hex character in VX
+	"bchar": (*Chip8).getBigFontCharacter,
+	// FX33 - take the value of VX, converts it to a denary number and the put each individual digit in the memory
+	// location specified by the index register + the digit number
+	"num": (*Chip8).convertToDecimal,
+	// FX55 - store the value of each general purpose register from V0 to VX inclusive in consecutive memory addresses
+	// starting from the current value of the index register
+	"load": (*Chip8).storeMemory,
+	// FX65 - loads the value of each general purpose register from V0 to VX inclusive from consecutive memory
+	// addresses starting from the current value of the index register
+	"save": (*Chip8).loadMemory,
+	// FX75 - store the value of each general purpose register from V0 to VX inclusive in the RPL flags
+	"rpls": (*Chip8).storeFlags,
+	// FX85 - loads the value of each general purpose register from V0 to VX inclusive from the RPL flags
+	"rplr": (*Chip8).loadFlags,
+	// FN01 - select the drawing planes in the mask N
+	"plane": (*Chip8).selectPlanes,
+	// F002 - load the 16 byte audio pattern from the memory location in the index register
+	"audio": (*Chip8).loadAudioPattern,
+	// FX3A - set the pitch register to the value of VX
+	"pitch": (*Chip8).setPitch,
+}
+
+// Step executes a single instruction. If the program is waiting for the next timer tick because of WaitForVBlank, or
+// has exited, nothing happens. If the instruction can't be executed, an *ExecError is returned.
+func (c *Chip8) Step() error {
+	if c.waitingForVBlank || c.exited {
+		return nil
+	}
+	return c.tick()
+}
 
-	case 0x90:
-		// 9XYN - skip one if registers not equal
-		c.skipNotEqRegReg()
-
-	case 0xA0:
-		// ANNN - set index register to constant
-		c.setIndexRegister()
-
-	case 0xB0:
-		// BNNN - set PC to NNN + V0 - if VariableOffsetRegister, BXNN - set PC to XNN + VX
-		c.jumpWithOffset()
-
-	case 0xC0:
-		// CXNN - generate a random byte, AND it with NN and store in VX
-		c.random()
-
-	case 0xD0:
-		// DXYN - display
-		c.display()
-
-	case 0xE0:
-		// Input
-		switch c.cir[1] {
-		case 0x9E:
-			// EX9E - skip one if key with the value stored in VX is pressed
-			c.skipIfKey()
-		case 0xA1:
-			// EXA1 - skip one if key with the value stored in VX is not pressed
-			c.skipIfNotKey()
-		default:
-			panic(fmt.Errorf("UNHANDLED at %x: %x\n", c.pc, c.cir))
+// RunCycles calls Step n times, stopping early if an error is returned or the program exits
+func (c *Chip8) RunCycles(n int) error {
+	for i := 0; i < n && !c.exited; i += 1 {
+		if err := c.Step(); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// RunFrame runs one sixtieth of a second's worth of instructions at the VM's clock speed, then decrements the delay
+// and sound timers as the 60Hz timer would.
+func (c *Chip8) RunFrame() error {
+	if err := c.RunCycles(c.cyclesPerFrame()); err != nil {
+		return err
+	}
+	c.timerTick()
+	return nil
+}
+
+// cyclesPerFrame returns the number of instructions executed between each 60Hz timer tick
+func (c *Chip8) cyclesPerFrame() int {
+	if n := c.clockSpeedHertz / 60; n > 0 {
+		return n
+	}
+	return 1
+}
 
-	case 0xF0:
-		switch c.cir[1] {
-		case 0x07:
-			// FX07 - set value of VX to the current value of the delay timer
-			c.getDelayTimer()
-		case 0x15:
-			// FX15 - set delay timer to value of VX
-			c.setDelayTimer()
-		case 0x18:
-			// FX18 - set sound timer to the value of VX
-			c.setSoundTimer()
-		case 0x0A:
-			// FX0A - blocks until a key is pressed. Stores that key's value in VX then continues.
-			c.getPressedKey()
-		case 0x1E:
-			// FX1E - adds the value of VX to the index register and set VF accordingly if the index register
-			// "overflows" above 0x0FFF
-			c.addToIndexRegister()
-		case 0x29:
-			// FX29 - set the index register to the address of the hex character in VX
-			c.getFontCharacter()
-		case 0x33:
-			// FX33 - take the value of VX, converts it to a denary number and the put each individual digit in
-			// the memory location specified by the index register + the digit number
-			c.convertToDecimal()
-		case 0x55:
-			// FX55 - store the value of each general purpose register from V0 to VX inclusive in consecutive
-			// memory addresses starting from the current value of the index register
-			c.storeMemory()
-		case 0x65:
-			// FX65 - loads the value of each general purpose register from V0 to VX inclusive from consecutive
-			// memory addresses starting from the current value of the index register
-			c.loadMemory()
-		default:
-			panic(fmt.Errorf("UNHANDLED at %x: %x\n", c.pc, c.cir))
+// timerTick is called at 60Hz to decrement the delay and sound timers, take a rewind snapshot and read the keypad for
+// the next frame
+func (c *Chip8) timerTick() {
+	decrement(&c.delay)
+	decrement(&c.sound)
+	c.waitingForVBlank = false
+
+	if c.sound == 0 {
+		c.ui.StopTone()
+	} else {
+		c.ui.StartTone()
+	}
+
+	c.recordFrame()
+
+	c.keys = 0
+	for _, key := range c.input.GetPressedKeys() {
+		if key < 16 {
+			c.keys |= 1 << key
 		}
+	}
+}
+
+// keyPressed returns true if key was pressed at the start of the current frame
+func (c *Chip8) keyPressed(key byte) bool {
+	return key < 16 && c.keys&(1<<key) != 0
+}
+
+// SetInputSource replaces the source of keypad input, which is the uiDriver passed to NewChip8 by default. It is safe
+// to call SetInputSource while Run is running in another goroutine.
+func (c *Chip8) SetInputSource(src InputSource) {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	c.input = src
+}
+
+// Pause stops Run from executing instructions and decrementing the timers until Resume is called. It is safe to call
+// Pause from any goroutine.
+func (c *Chip8) Pause() {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	c.paused = true
+	c.ui.StopTone()
+}
+
+// Resume undoes Pause. It is safe to call Resume from any goroutine.
+func (c *Chip8) Resume() {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	c.paused = false
+}
+
+// Paused returns true if the VM has been paused with Pause
+func (c *Chip8) Paused() bool {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	return c.paused
+}
 
-	default:
-		panic(fmt.Errorf("UNHANDLED at %x: %x\n", c.pc, c.cir))
+// SetClockSpeed changes the approximate number of instructions executed per second. It is safe to call SetClockSpeed
+// from any goroutine, and takes effect from the next frame if Run is running.
+func (c *Chip8) SetClockSpeed(hz int) {
+	if hz < 1 {
+		hz = 1
 	}
+
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	c.clockSpeedHertz = hz
 }
 
-func (c *Chip8) Run() {
+// ClockSpeed returns the approximate number of instructions executed per second
+func (c *Chip8) ClockSpeed() int {
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	return c.clockSpeedHertz
+}
 
-	programTicker := time.NewTicker(time.Second / time.Duration(c.clockSpeedHertz))
-	defer programTicker.Stop()
+// Run executes the loaded program until it exits, an instruction can't be executed or ctx is cancelled. If an
+// instruction can't be executed, an *ExecError is returned, and if ctx is cancelled, ctx.Err() is returned.
+//
+// Run should not be called at the same time as Step, RunCycles or RunFrame.
+func (c *Chip8) Run(ctx context.Context) error {
 
-	decrementTicker := time.NewTicker(time.Second / 60)
-	defer decrementTicker.Stop()
+	// running a whole frame at a time means the same number of instructions are executed between each timer tick,
+	// whatever the scheduling of this goroutine, so that runs with the same input are identical
+	frameTicker := time.NewTicker(time.Second / framesPerSecond)
+	defer frameTicker.Stop()
 
-	done := make(chan bool)
+	defer c.ui.StopTone()
 
-MAINLOOP:
 	for {
 		select {
-		case <-done:
-			break MAINLOOP
-		case <-decrementTicker.C:
-			decrement(&c.delay)
-			decrement(&c.sound)
-
-			if c.sound == 0 {
-				c.ui.StopTone()
-			} else {
-				c.ui.StartTone()
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-frameTicker.C:
+			c.runLock.Lock()
+			var err error
+			if !c.paused {
+				err = c.RunFrame()
 			}
+			exited := c.exited
+			c.runLock.Unlock()
 
-			// TODO: Make noise!
-		case <-programTicker.C:
-
-			c.tick()
+			if err != nil {
+				return err
+			}
 
+			if exited {
+				return nil
+			}
 		}
 	}
 }
@@ -0,0 +1,171 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/emulator/vm/vm_test.go
+
+package vm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Step(t *testing.T) {
+	// set v0 0x01, add v0 0x01
+	c, _ := vmFixtureWithoutTick([]byte{0x60, 0x01, 0x70, 0x01})
+
+	if err := c.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if c.pc != 0x202 || c.v0 != 0x01 {
+		t.Fatalf("Step did not execute exactly one instruction (pc %x, v0 %x)", c.pc, c.v0)
+	}
+
+	c.waitingForVBlank = true
+	if err := c.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if c.pc != 0x202 {
+		t.Fatal("Step executed an instruction while waiting for the next timer tick")
+	}
+}
+
+func Test_RunCycles(t *testing.T) {
+	// add v0 0x01, jmp 0x200
+	c, _ := vmFixtureWithoutTick([]byte{0x70, 0x01, 0x12, 0x00})
+
+	if err := c.RunCycles(10); err != nil {
+		t.Fatal(err)
+	}
+	if c.v0 != 5 {
+		t.Fatalf("RunCycles executed the wrong number of instructions (got v0 %d, want 5)", c.v0)
+	}
+
+	// exit, which should stop RunCycles early
+	c, _ = vmFixtureWithoutTick([]byte{0x00, 0xFD, 0x70, 0x01})
+	if err := c.RunCycles(10); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Exited() || c.v0 != 0 {
+		t.Fatal("RunCycles continued after the program exited")
+	}
+}
+
+func Test_RunFrame(t *testing.T) {
+	// add v0 0x01, jmp 0x200
+	c, _ := vmFixtureWithoutTick([]byte{0x70, 0x01, 0x12, 0x00})
+	c.clockSpeedHertz = 600
+	c.delay = 2
+
+	if err := c.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if c.v0 != 5 {
+		t.Fatalf("RunFrame executed the wrong number of instructions (got v0 %d, want 5)", c.v0)
+	}
+	if c.delay != 1 {
+		t.Fatalf("RunFrame did not decrement the delay timer (got %d, want 1)", c.delay)
+	}
+}
+
+func Test_Reset(t *testing.T) {
+	// set v0 0x01, idx 0x300, save v0, call 0x200
+	rom := []byte{0x60, 0x01, 0xA3, 0x00, 0xF0, 0x55, 0x22, 0x00}
+	c, u := vmFixtureWithoutTick(rom)
+	c.Quirks.ClipSprites = !c.Quirks.ClipSprites
+	quirks := c.Quirks
+
+	if err := c.RunCycles(4); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Reset()
+
+	if c.pc != programStart || c.v0 != 0 || c.ir != 0 || len(c.stack) != 0 {
+		t.Fatal("Reset did not reset the registers")
+	}
+	if c.memory[0x300] != 0 {
+		t.Fatal("Reset did not clear memory")
+	}
+	if c.memory[programStart] != rom[0] || c.memory[fontLocation] == 0 {
+		t.Fatal("Reset did not reload the ROM and fonts")
+	}
+	if c.Quirks != quirks {
+		t.Fatal("Reset changed the quirks")
+	}
+	if u.output == nil || *u.output != (Display{}) {
+		t.Fatal("Reset did not publish an empty display")
+	}
+}
+
+func Test_RunCancel(t *testing.T) {
+	// jmp 0x200
+	c, _ := vmFixtureWithoutTick([]byte{0x12, 0x00})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- c.Run(ctx)
+	}()
+
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Run returned %v after cancellation, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
+
+func Test_PauseResume(t *testing.T) {
+	// add v0 0x01, jmp 0x200
+	c, _ := vmFixtureWithoutTick([]byte{0x70, 0x01, 0x12, 0x00})
+	c.SetClockSpeed(10000)
+	c.Pause()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+	c.runLock.Lock()
+	v0 := c.v0
+	c.runLock.Unlock()
+	if v0 != 0 {
+		t.Fatal("instructions were executed while paused")
+	}
+
+	c.Resume()
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.runLock.Lock()
+		v0 = c.v0
+		c.runLock.Unlock()
+		if v0 != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("instructions were not executed after resuming")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_SetClockSpeed(t *testing.T) {
+	c, _ := vmFixtureWithoutTick(nil)
+
+	c.SetClockSpeed(1200)
+	if c.ClockSpeed() != 1200 || c.cyclesPerFrame() != 20 {
+		t.Fatalf("clock speed was not changed (got %d)", c.ClockSpeed())
+	}
+
+	c.SetClockSpeed(0)
+	if c.ClockSpeed() != 1 {
+		t.Fatalf("clock speed below 1Hz was not clamped (got %d)", c.ClockSpeed())
+	}
+}
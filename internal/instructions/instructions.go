@@ -0,0 +1,164 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/instructions/instructions.go
+
+// Package instructions contains the opcode table shared by the emulator, the assembler and the disassembler.
+package instructions
+
+// Format describes which parts of an opcode are operands
+type Format uint8
+
+const (
+	FormatNone           Format = iota // ----
+	FormatAddress                      // -NNN
+	FormatRegister                     // -X--
+	FormatRegisterConst                // -XNN
+	FormatRegisterReg                  // -XY-
+	FormatRegisterOptReg               // -XY-, where Y defaults to X if omitted in assembly source
+	FormatRegisterRegNib               // -XYN
+	FormatNibble                       // ---N
+	FormatPlanes                       // -N--
+	FormatLongAddress                  // ---- NNNN
+)
+
+// Mask returns a mask of the bits of an opcode that are fixed for an instruction with this format
+func (f Format) Mask() uint16 {
+	switch f {
+	case FormatNone, FormatLongAddress:
+		return 0xFFFF
+	case FormatRegister, FormatPlanes:
+		return 0xF0FF
+	case FormatNibble:
+		return 0xFFF0
+	case FormatRegisterReg, FormatRegisterOptReg:
+		return 0xF00F
+	default:
+		return 0xF000
+	}
+}
+
+// Definition describes a single instruction
+type Definition struct {
+	Mnemonic string
+	Base     uint16
+	Format   Format
+}
+
+// Size returns the number of bytes used by the instruction
+func (d Definition) Size() int {
+	if d.Format == FormatLongAddress {
+		return 4
+	}
+	return 2
+}
+
+// Matches reports if opcode is an encoding of this instruction. For instructions longer than two bytes, opcode is the
+// first two bytes.
+func (d Definition) Matches(opcode uint16) bool {
+	return opcode&d.Format.Mask() == d.Base
+}
+
+// Set lists every instruction in asmSyntax.txt with the opcode it assembles to, including the SCHIP and XO-CHIP
+// extensions
+var Set = []Definition{
+	{"clr", 0x00E0, FormatNone},
+	{"rtn", 0x00EE, FormatNone},
+	{"scrd", 0x00C0, FormatNibble},
+	{"scru", 0x00D0, FormatNibble},
+	{"scrr", 0x00FB, FormatNone},
+	{"scrl", 0x00FC, FormatNone},
+	{"exit", 0x00FD, FormatNone},
+	{"lres", 0x00FE, FormatNone},
+	{"hres", 0x00FF, FormatNone},
+	{"jmp", 0x1000, FormatAddress},
+	{"call", 0x2000, FormatAddress},
+	{"src", 0x3000, FormatRegisterConst},
+	{"srcx", 0x4000, FormatRegisterConst},
+	{"srr", 0x5000, FormatRegisterReg},
+	{"srrx", 0x9000, FormatRegisterReg},
+	{"loadr", 0x5002, FormatRegisterReg},
+	{"saver", 0x5003, FormatRegisterReg},
+	{"set", 0x6000, FormatRegisterConst},
+	{"add", 0x7000, FormatRegisterConst},
+	{"copy", 0x8000, FormatRegisterReg},
+	{"or", 0x8001, FormatRegisterReg},
+	{"and", 0x8002, FormatRegisterReg},
+	{"xor", 0x8003, FormatRegisterReg},
+	{"sum", 0x8004, FormatRegisterReg},
+	{"sub", 0x8005, FormatRegisterReg},
+	{"bsub", 0x8007, FormatRegisterReg},
+	{"rsh", 0x8006, FormatRegisterOptReg},
+	{"lsh", 0x800E, FormatRegisterOptReg},
+	{"idx", 0xA000, FormatAddress},
+	{"idxl", 0xF000, FormatLongAddress},
+	{"idxs", 0xF01E, FormatRegister},
+	{"jmpo", 0xB000, FormatAddress},
+	{"rand", 0xC000, FormatRegisterConst},
+	{"disp", 0xD000, FormatRegisterRegNib},
+	{"skp", 0xE09E, FormatRegister},
+	{"skpx", 0xE0A1, FormatRegister},
+	{"inp", 0xF00A, FormatRegister},
+	{"dget", 0xF007, FormatRegister},
+	{"dset", 0xF015, FormatRegister},
+	{"sset", 0xF018, FormatRegister},
+	{"char", 0xF029, FormatRegister},
+	{"bchar", 0xF030, FormatRegister},
+	{"num", 0xF033, FormatRegister},
+	{"load", 0xF055, FormatRegister},
+	{"save", 0xF065, FormatRegister},
+	{"rpls", 0xF075, FormatRegister},
+	{"rplr", 0xF085, FormatRegister},
+	{"plane", 0xF001, FormatPlanes},
+	{"audio", 0xF002, FormatNone},
+	{"pitch", 0xF03A, FormatRegister},
+}
+
+var byMnemonic = make(map[string]Definition)
+
+func init() {
+	for _, def := range Set {
+		byMnemonic[def.Mnemonic] = def
+	}
+}
+
+// Lookup returns the definition of the instruction with the given mnemonic
+func Lookup(mnemonic string) (Definition, bool) {
+	def, found := byMnemonic[mnemonic]
+	return def, found
+}
+
+// Decode returns the definition of the instruction that opcode is an encoding of
+func Decode(opcode uint16) (Definition, bool) {
+	for _, def := range Set {
+		if def.Matches(opcode) {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
+
+// X returns the X register number (-X--) from opcode
+func X(opcode uint16) uint8 {
+	return uint8(opcode>>8) & 0xF
+}
+
+// Y returns the Y register number (--Y-) from opcode
+func Y(opcode uint16) uint8 {
+	return uint8(opcode>>4) & 0xF
+}
+
+// N returns the 4-bit constant (---N) from opcode
+func N(opcode uint16) uint8 {
+	return uint8(opcode) & 0xF
+}
+
+// NN returns the 8-bit constant (--NN) from opcode
+func NN(opcode uint16) uint8 {
+	return uint8(opcode)
+}
+
+// NNN returns the 12-bit address (-NNN) from opcode
+func NNN(opcode uint16) uint16 {
+	return opcode & 0x0FFF
+}
@@ -0,0 +1,25 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/instructions/instructions_test.go
+
+package instructions
+
+import "testing"
+
+func Test_Decode(t *testing.T) {
+	for _, def := range Set {
+		// set every operand bit to make sure it doesn't change which instruction is matched
+		opcode := def.Base | ^def.Format.Mask()
+		got, found := Decode(opcode)
+		if !found || got.Mnemonic != def.Mnemonic {
+			t.Errorf("%04x decoded as %q, want %q", opcode, got.Mnemonic, def.Mnemonic)
+		}
+	}
+
+	for _, opcode := range []uint16{0x0000, 0x00E1, 0x5121, 0x8008, 0x912F, 0xE19F, 0xF1FF} {
+		if def, found := Decode(opcode); found {
+			t.Errorf("%04x should not decode, got %q", opcode, def.Mnemonic)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/movie/movie.go
+
+// Package movie records the keypad input of a run of a ROM, frame by frame, so that it can be played back exactly.
+//
+// A movie file is a header describing the settings the VM was run with, followed by one big-endian 16-bit mask of the
+// pressed keys for every frame, where bit N is set if key N was pressed.
+package movie
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/codemicro/chip8/internal/emulator/vm"
+	"io"
+	"io/ioutil"
+)
+
+// magic is the first four bytes of every movie file
+var magic = [4]byte{'C', '8', 'M', 'V'}
+
+// version is the version of the movie format written by Recorder. It must be increased whenever the layout of Header
+// changes, including when fields are added to vm.Quirks.
+const version uint16 = 1
+
+var (
+	ErrNotMovie    = errors.New("not a movie file")
+	ErrVersion     = errors.New("unsupported movie version")
+	ErrROMMismatch = errors.New("movie was recorded with a different ROM")
+)
+
+// Header holds everything that affects a run of a ROM other than the input. Playing a movie back with the same
+// settings it was recorded with reproduces the run exactly.
+type Header struct {
+	ROMHash       [sha1.Size]byte
+	Seed          int64
+	ClockSpeed    uint32
+	Quirks        vm.Quirks
+	MemoryPolicy  vm.MemoryPolicy
+	MemorySize    uint32
+	MaxStackDepth uint32
+}
+
+// CheckROM returns ErrROMMismatch if the movie wasn't recorded with rom
+func (h Header) CheckROM(rom []byte) error {
+	if h.ROMHash != sha1.Sum(rom) {
+		return ErrROMMismatch
+	}
+	return nil
+}
+
+// fileHeader is written at the start of every movie file
+type fileHeader struct {
+	Magic   [4]byte
+	Version uint16
+	Header  Header
+}
+
+// keysToMask converts a list of pressed keys to a mask
+func keysToMask(keys []uint8) uint16 {
+	var mask uint16
+	for _, key := range keys {
+		if key < 16 {
+			mask |= 1 << key
+		}
+	}
+	return mask
+}
+
+// maskToKeys converts a mask to a list of pressed keys in ascending order
+func maskToKeys(mask uint16) []uint8 {
+	var keys []uint8
+	for key := uint8(0); key < 16; key += 1 {
+		if mask&(1<<key) != 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Recorder is a vm.InputSource that passes through the keys from another source, writing them to a movie as it goes
+type Recorder struct {
+	source vm.InputSource
+	w      *bufio.Writer
+	err    error
+}
+
+// NewRecorder writes header to w and returns a Recorder that records the keys from source to w
+func NewRecorder(w io.Writer, header Header, source vm.InputSource) (*Recorder, error) {
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.BigEndian, &fileHeader{Magic: magic, Version: version, Header: header}); err != nil {
+		return nil, err
+	}
+	return &Recorder{source: source, w: bw}, nil
+}
+
+// GetPressedKeys returns the keys pressed in source and records them as the next frame
+func (r *Recorder) GetPressedKeys() []uint8 {
+	keys := r.source.GetPressedKeys()
+	if r.err == nil {
+		r.err = binary.Write(r.w, binary.BigEndian, keysToMask(keys))
+	}
+	return keys
+}
+
+// Close finishes writing the movie, returning the first error that occurred while recording. It doesn't close the
+// underlying writer. Close must not be called at the same time as GetPressedKeys.
+func (r *Recorder) Close() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
+
+// Player is a vm.InputSource that plays back the keys recorded in a movie. Once every frame has been played, no keys
+// are pressed.
+type Player struct {
+	header Header
+	frames []uint16
+
+	position int
+	// done is closed once position reaches the end of frames
+	done chan struct{}
+}
+
+// Load reads a movie from r
+func Load(r io.Reader) (*Player, error) {
+	var fh fileHeader
+	if err := binary.Read(r, binary.BigEndian, &fh); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotMovie, err)
+	}
+	if fh.Magic != magic {
+		return nil, ErrNotMovie
+	}
+	if fh.Version != version {
+		return nil, fmt.Errorf("%w %d", ErrVersion, fh.Version)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("%w: truncated frame", ErrNotMovie)
+	}
+
+	p := &Player{
+		header: fh.Header,
+		frames: make([]uint16, len(data)/2),
+		done:   make(chan struct{}),
+	}
+	for i := range p.frames {
+		p.frames[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	if len(p.frames) == 0 {
+		close(p.done)
+	}
+	return p, nil
+}
+
+// Header returns the settings the movie was recorded with
+func (p *Player) Header() Header {
+	return p.header
+}
+
+// Frames returns the number of frames in the movie
+func (p *Player) Frames() int {
+	return len(p.frames)
+}
+
+// GetPressedKeys returns the keys pressed in the next frame of the movie
+func (p *Player) GetPressedKeys() []uint8 {
+	if p.position >= len(p.frames) {
+		return nil
+	}
+	keys := maskToKeys(p.frames[p.position])
+	p.position += 1
+	if p.position == len(p.frames) {
+		close(p.done)
+	}
+	return keys
+}
+
+// Done returns a channel that's closed once every frame in the movie has been played
+func (p *Player) Done() <-chan struct{} {
+	return p.done
+}
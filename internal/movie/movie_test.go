@@ -0,0 +1,168 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/movie/movie_test.go
+
+package movie
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"github.com/codemicro/chip8/internal/emulator/vm"
+	"testing"
+)
+
+// testROM draws the "5" character at a random position whenever key 5 is pressed
+var testROM = []byte{
+	0xC0, 0x3F, // rand $0 0x3F
+	0xC1, 0x1F, // rand $1 0x1F
+	0x62, 0x05, // set $2 5
+	0xE2, 0x9E, // skp $2
+	0x12, 0x00, // jmp 0x200
+	0xF2, 0x29, // char $2
+	0xD0, 0x15, // disp $0 $1 5
+	0x12, 0x00, // jmp 0x200
+}
+
+// headless is a UI driver that keeps the last published display and takes input from a script
+type headless struct {
+	display vm.Display
+	frame   int
+}
+
+func (h *headless) PublishNewDisplay(d vm.Display)                       { h.display = d }
+func (h *headless) StartTone()                                           {}
+func (h *headless) StopTone()                                            {}
+func (h *headless) SetAudioPattern(pattern vm.AudioPattern, pitch uint8) {}
+func (h *headless) ClearAudioPattern()                                   {}
+
+func (h *headless) GetPressedKeys() []uint8 {
+	h.frame += 1
+	if h.frame%3 == 0 {
+		return []uint8{0x05, 0x0A}
+	}
+	return nil
+}
+
+func testHeader() Header {
+	return Header{
+		ROMHash:       sha1.Sum(testROM),
+		Seed:          1234,
+		ClockSpeed:    600,
+		Quirks:        vm.QuirksPresets["schip-modern"],
+		MemoryPolicy:  vm.MemoryWrap,
+		MemorySize:    vm.DefaultMemorySize,
+		MaxStackDepth: vm.DefaultStackDepth,
+	}
+}
+
+// newVM creates a VM with the settings in h
+func newVM(h Header, ui *headless) *vm.Chip8 {
+	c := vm.NewChip8(testROM, ui, int(h.ClockSpeed))
+	c.Quirks = h.Quirks
+	c.MemoryPolicy = h.MemoryPolicy
+	c.MemorySize = int(h.MemorySize)
+	c.MaxStackDepth = int(h.MaxStackDepth)
+	c.SetRandomSource(vm.NewRandomSource(h.Seed))
+	return c
+}
+
+func Test_RecordAndPlay(t *testing.T) {
+	const frames = 120
+
+	// record
+	header := testHeader()
+	recordUI := &headless{}
+	c := newVM(header, recordUI)
+
+	movie := new(bytes.Buffer)
+	rec, err := NewRecorder(movie, header, recordUI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetInputSource(rec)
+
+	for i := 0; i < frames; i += 1 {
+		if err := c.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if recordUI.display == (vm.Display{}) {
+		t.Fatal("nothing was drawn while recording")
+	}
+
+	// play back
+	player, err := Load(bytes.NewReader(movie.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if player.Header() != header {
+		t.Fatal("header was not read back correctly")
+	}
+	if err := player.Header().CheckROM(testROM); err != nil {
+		t.Fatal(err)
+	}
+	if player.Frames() != frames {
+		t.Fatalf("incorrect number of frames (got %d, want %d)", player.Frames(), frames)
+	}
+
+	playUI := &headless{}
+	c = newVM(player.Header(), playUI)
+	c.SetInputSource(player)
+
+	for i := 0; i < frames; i += 1 {
+		select {
+		case <-player.Done():
+			t.Fatalf("playback finished early, at frame %d", i)
+		default:
+		}
+		if err := c.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-player.Done():
+	default:
+		t.Fatal("playback did not finish")
+	}
+
+	if playUI.display != recordUI.display {
+		t.Fatal("played back display differs from the recorded display")
+	}
+}
+
+func Test_LoadErrors(t *testing.T) {
+	if _, err := Load(bytes.NewReader([]byte("nope"))); !errors.Is(err, ErrNotMovie) {
+		t.Fatalf("loading garbage returned %v, want %v", err, ErrNotMovie)
+	}
+
+	movie := new(bytes.Buffer)
+	rec, err := NewRecorder(movie, testHeader(), &headless{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.GetPressedKeys()
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := movie.Bytes()
+	if _, err := Load(bytes.NewReader(b[:len(b)-1])); !errors.Is(err, ErrNotMovie) {
+		t.Fatalf("loading a truncated movie returned %v, want %v", err, ErrNotMovie)
+	}
+
+	b[5] += 1 // version
+	if _, err := Load(bytes.NewReader(b)); !errors.Is(err, ErrVersion) {
+		t.Fatalf("loading a movie with an unknown version returned %v, want %v", err, ErrVersion)
+	}
+
+	h := testHeader()
+	if err := h.CheckROM([]byte{0x12, 0x00}); !errors.Is(err, ErrROMMismatch) {
+		t.Fatalf("checking a different ROM returned %v, want %v", err, ErrROMMismatch)
+	}
+}
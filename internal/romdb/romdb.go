@@ -0,0 +1,78 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/romdb/romdb.go
+
+// Package romdb identifies ROMs by their SHA-1 hash and provides the settings they are known to need.
+package romdb
+
+import (
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:embed roms.json
+var embedded []byte
+
+// Entry describes a single ROM
+type Entry struct {
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	// Platform is the system the ROM was written for, such as chip8, schip or xo-chip
+	Platform string `json:"platform"`
+	// Quirks is the name of the quirks preset the ROM needs
+	Quirks string `json:"quirks"`
+	// ClockSpeed is the recommended clock speed in hertz
+	ClockSpeed int `json:"clock"`
+	// Keys maps CHIP-8 keys (as a single hex digit) to a description of what they do
+	Keys map[string]string `json:"keys"`
+	// Foreground, Background, Foreground2 and Blend are hex colours. Foreground2 is used for pixels set only in the
+	// second XO-CHIP drawing plane and Blend for pixels set in both.
+	Foreground  string `json:"foreground"`
+	Background  string `json:"background"`
+	Foreground2 string `json:"foreground2"`
+	Blend       string `json:"blend"`
+}
+
+// Database maps lowercase hex-encoded SHA-1 hashes of ROMs to their metadata
+type Database map[string]*Entry
+
+// Parse reads a database in JSON format
+func Parse(data []byte) (Database, error) {
+	var raw Database
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid ROM database: %w", err)
+	}
+
+	db := make(Database, len(raw))
+	for hash, entry := range raw {
+		hash = strings.ToLower(hash)
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha1.Size {
+			return nil, fmt.Errorf("invalid ROM database: %#v is not a SHA-1 hash", hash)
+		}
+		db[hash] = entry
+	}
+
+	return db, nil
+}
+
+// Embedded returns the database built into the program
+func Embedded() (Database, error) {
+	return Parse(embedded)
+}
+
+// Hash returns the hex-encoded SHA-1 hash of rom
+func Hash(rom []byte) string {
+	h := sha1.Sum(rom)
+	return hex.EncodeToString(h[:])
+}
+
+// Lookup returns the entry for rom, if there is one
+func (db Database) Lookup(rom []byte) (*Entry, bool) {
+	entry, found := db[Hash(rom)]
+	return entry, found
+}
@@ -0,0 +1,98 @@
+// https://github.com/codemicro/chip8
+// Copyright (c) 2021, codemicro and contributors
+// SPDX-License-Identifier: MIT
+// Filename: internal/romdb/romdb_test.go
+
+package romdb
+
+import (
+	"testing"
+
+	"github.com/codemicro/chip8/internal/emulator/vm"
+)
+
+func Test_Lookup(t *testing.T) {
+	rom := []byte{0x00, 0xE0, 0x12, 0x00}
+
+	db, err := Parse([]byte(`{
+		"E8F1D0C3A8B4E2DFA8B5F5A1F2C4D3E6B7A8C9D0": {"title": "Other"},
+		"` + Hash(rom) + `": {
+			"title": "Clear",
+			"platform": "chip8",
+			"quirks": "vip",
+			"clock": 700,
+			"keys": {"5": "fire"}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry, found := db.Lookup(rom)
+	if !found {
+		t.Fatalf("ROM with hash %s not found", Hash(rom))
+	}
+	if entry.Title != "Clear" || entry.Quirks != "vip" || entry.ClockSpeed != 700 || entry.Keys["5"] != "fire" {
+		t.Errorf("incorrect entry (got %+v)", entry)
+	}
+
+	if _, found := db.Lookup([]byte{0x12, 0x00}); found {
+		t.Errorf("unexpected entry for unknown ROM")
+	}
+
+	if _, err := Parse([]byte(`{"abc": {}}`)); err == nil {
+		t.Errorf("expected error for invalid hash, got none")
+	}
+
+}
+
+// ibmLogoROM is the well known IBM logo program, which draws the logo and loops forever. Its MD5 hash is
+// 2dbace8066709ac9a264d23281820d32.
+var ibmLogoROM = []byte{
+	0x00, 0xE0, 0xA2, 0x2A, 0x60, 0x0C, 0x61, 0x08, 0xD0, 0x1F, 0x70, 0x09, 0xA2, 0x39, 0xD0, 0x1F,
+	0xA2, 0x48, 0x70, 0x08, 0xD0, 0x1F, 0x70, 0x04, 0xA2, 0x57, 0xD0, 0x1F, 0x70, 0x08, 0xA2, 0x66,
+	0xD0, 0x1F, 0x70, 0x08, 0xA2, 0x75, 0xD0, 0x1F, 0x12, 0x28, 0xFF, 0x00, 0xFF, 0x00, 0x3C, 0x00,
+	0x3C, 0x00, 0x3C, 0x00, 0x3C, 0x00, 0xFF, 0x00, 0xFF, 0xFF, 0x00, 0xFF, 0x00, 0x38, 0x00, 0x3F,
+	0x00, 0x3F, 0x00, 0x38, 0x00, 0xFF, 0x00, 0xFF, 0x80, 0x00, 0xE0, 0x00, 0xE0, 0x00, 0x80, 0x00,
+	0x80, 0x00, 0xE0, 0x00, 0xE0, 0x00, 0x80, 0xF8, 0x00, 0xFC, 0x00, 0x3E, 0x00, 0x3F, 0x00, 0x3B,
+	0x00, 0x39, 0x00, 0xF8, 0x00, 0xF8, 0x03, 0x00, 0x07, 0x00, 0x0F, 0x00, 0xBF, 0x00, 0xFB, 0x00,
+	0xF3, 0x00, 0xE3, 0x00, 0x43, 0xE0, 0x00, 0xE0, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80,
+	0x00, 0xE0, 0x00, 0xE0,
+}
+
+func Test_Embedded(t *testing.T) {
+	db, err := Embedded()
+	if err != nil {
+		t.Fatalf("embedded database is invalid: %v", err)
+	}
+	if len(db) == 0 {
+		t.Fatal("embedded database is empty")
+	}
+
+	entry, found := db.Lookup(ibmLogoROM)
+	if !found {
+		t.Fatalf("IBM logo ROM with hash %s not found", Hash(ibmLogoROM))
+	}
+	if entry.Title != "IBM Logo" || entry.Platform != "chip8" || entry.Quirks != "vip" {
+		t.Errorf("incorrect entry for IBM logo ROM (got %+v)", entry)
+	}
+
+	// the beep test from Timendus' CHIP-8 test suite, as released in November 2025
+	beep, found := db["b119651b5aa08557a85ca2ad5de3d1a86796b66b"]
+	if !found {
+		t.Fatal("beep test ROM not found")
+	}
+	if beep.Quirks != "vip" || beep.ClockSpeed != 1200 || beep.Foreground != "FFCC00" || beep.Keys["B"] != "beep" {
+		t.Errorf("incorrect entry for beep test ROM (got %+v)", beep)
+	}
+
+	// every entry should name a quirks preset that exists, or none at all
+	for hash, entry := range db {
+		if entry.Quirks == "" {
+			continue
+		}
+		if _, err := vm.QuirksPreset(entry.Quirks); err != nil {
+			t.Errorf("entry %s (%s): %v", hash, entry.Title, err)
+		}
+	}
+}
@@ -18,14 +18,25 @@ import (
 )
 
 func Build() error {
-	const buildPackage = "github.com/codemicro/chip8/cmd/c8run"
+	var buildPackages = []string{
+		"github.com/codemicro/chip8/cmd/c8run",
+		"github.com/codemicro/chip8/cmd/c8asm",
+		"github.com/codemicro/chip8/cmd/c8dis",
+		"github.com/codemicro/chip8/cmd/c8test",
+	}
 
 	outputDir := filepath.Join("bin", fmt.Sprintf("%s-%s", exmg.GetTargetOS(), exmg.GetTargetArch()))
-	basePackageName := filepath.Base(buildPackage)
 
 	_ = os.MkdirAll(outputDir, os.ModeDir)
 
-	return sh.Run("go", "build", "-o", filepath.Join(outputDir, basePackageName), buildPackage)
+	for _, buildPackage := range buildPackages {
+		basePackageName := filepath.Base(buildPackage)
+		if err := sh.Run("go", "build", "-o", filepath.Join(outputDir, basePackageName), buildPackage); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func Test() error {